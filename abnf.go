@@ -0,0 +1,359 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// core rules from RFC 5234, added when referenced but not defined
+const abnfCore = `
+ALPHA  = %x41-5A / %x61-7A
+BIT    = "0" / "1"
+CHAR   = %x01-7F
+CR     = %x0D
+CRLF   = CR LF
+CTL    = %x00-1F / %x7F
+DIGIT  = %x30-39
+DQUOTE = %x22
+HEXDIG = DIGIT / "A" / "B" / "C" / "D" / "E" / "F"
+HTAB   = %x09
+LF     = %x0A
+LWSP   = *(WSP / CRLF WSP)
+OCTET  = %x00-FF
+SP     = %x20
+VCHAR  = %x21-7E
+WSP    = SP / HTAB
+`
+
+type abnfRule struct {
+	name        string
+	src         string
+	incremental bool // `=/`
+	node        *gnode
+}
+
+// add the rules of an ABNF grammar (RFC 5234 and RFC 7405)
+// rule names are case-insensitive, and `-` is replaced by `_`; the core rules (ALPHA, DIGIT, CRLF...) are added if used
+// whitespaces must be explicit, like in ABNF, and the alternatives are tried in order
+// each element of a sequence produces an item, so actions can be attached later using Alt(name).Prods()
+func (this *Grammar) ImportABNF(fileName string, text []byte) error {
+	return this.importing(func() error { return this.importABNF(fileName, text) })
+}
+
+func (this *Grammar) importABNF(fileName string, text []byte) error {
+	rules, err := parseABNF(fileName, string(text))
+	if err != nil {
+		return err
+	}
+	core, err := parseABNF("RFC5234", abnfCore)
+	if err != nil {
+		panic(err)
+	}
+
+	// rule names are case-insensitive, use the first spelling found
+	names := map[string]string{}
+	for _, r := range rules {
+		if names[strings.ToLower(r.name)] == "" {
+			names[strings.ToLower(r.name)] = r.name
+		}
+	}
+	coreRules := map[string]abnfRule{}
+	for _, r := range core {
+		coreRules[strings.ToLower(r.name)] = r
+	}
+	var rename func(n *gnode)
+	rename = func(n *gnode) {
+		if n.kind == gRef {
+			key := strings.ToLower(n.text)
+			if names[key] == "" {
+				if r, ok := coreRules[key]; ok {
+					names[key] = r.name
+					if alt := this.alts[r.name]; alt == nil || len(alt.prods) == 0 {
+						rules = append(rules, r) // unless a previous import added it
+						rename(r.node)
+					}
+				}
+			}
+			if names[key] != "" {
+				n.text = names[key]
+			}
+		}
+		for _, s := range n.sub {
+			rename(s)
+		}
+	}
+	for i := 0; i < len(rules); i++ {
+		rules[i].name = names[strings.ToLower(rules[i].name)]
+		rename(rules[i].node)
+	}
+
+	imp := importer{g: this}
+	for _, r := range rules {
+		if !r.incremental && len(this.Alt(r.name).prods) > 0 {
+			return ctx.NewErrorf(nil, "%s: rule %s already defined, use `=/` to add alternatives", r.src, r.name)
+		}
+		if err := imp.add(r.name, r.src, r.node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// split the text into rules, a rule continues on the lines starting with whitespaces
+func parseABNF(fileName, text string) ([]abnfRule, error) {
+	var out []abnfRule
+	var cur strings.Builder
+	start := 0
+	flush := func() error {
+		s := strings.TrimSpace(cur.String())
+		cur.Reset()
+		if s == "" {
+			return nil
+		}
+		src := fmt.Sprintf("%s:%d", fileName, start)
+		r, err := parseABNFRule(s)
+		if err != nil {
+			return ctx.NewErrorf(nil, "%s: %v", src, err)
+		}
+		r.src = src
+		out = append(out, r)
+		return nil
+	}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(abnfComment(line), " \t\r")
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			start = i + 1
+		} else if start == 0 {
+			return nil, ctx.NewErrorf(nil, "%s:%d: unexpected indentation", fileName, i+1)
+		}
+		cur.WriteString(line)
+		cur.WriteString(" ")
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// remove the `; comment`, if it's not in a quoted string
+func abnfComment(line string) string {
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			return line[0:i]
+		}
+	}
+	return line
+}
+
+type abnfScanner struct {
+	s  string
+	at int
+}
+
+func (this *abnfScanner) ws() {
+	for this.at < len(this.s) && strings.ContainsRune(" \t\r\n", rune(this.s[this.at])) {
+		this.at++
+	}
+}
+
+func (this *abnfScanner) match(re *regexp.Regexp) []string {
+	m := re.FindStringSubmatch(this.s[this.at:])
+	if m != nil {
+		this.at += len(m[0])
+	}
+	return m
+}
+
+func (this *abnfScanner) errorf(f string, args ...any) error {
+	rem := this.s[this.at:]
+	if len(rem) > 20 {
+		rem = rem[0:20]
+	}
+	return ctx.NewErrorf(nil, "%s at %q", fmt.Sprintf(f, args...), rem)
+}
+
+var (
+	abnfName   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*`)
+	abnfDefAs  = regexp.MustCompile(`^\s*(=/|=)`)
+	abnfRepeat = regexp.MustCompile(`^(\d*)(\*(\d*))?`)
+	abnfString = regexp.MustCompile(`^(%[sSiI])?"([^"]*)"`)
+	abnfNum    = regexp.MustCompile(`^%([xXdDbB])([0-9A-Fa-f]+)((?:\.[0-9A-Fa-f]+)+|-[0-9A-Fa-f]+)?`)
+)
+
+func parseABNFRule(s string) (abnfRule, error) {
+	sc := &abnfScanner{s: s}
+	m := sc.match(abnfName)
+	if m == nil {
+		return abnfRule{}, sc.errorf("expected a rule name")
+	}
+	r := abnfRule{name: ruleName(m[0])}
+	def := sc.match(abnfDefAs)
+	if def == nil {
+		return r, sc.errorf("expected `=` or `=/`")
+	}
+	r.incremental = def[1] == "=/"
+	n, err := sc.alternation()
+	if err != nil {
+		return r, err
+	}
+	sc.ws()
+	if sc.at < len(sc.s) {
+		return r, sc.errorf("unexpected text")
+	}
+	r.node = n
+	return r, nil
+}
+
+func (this *abnfScanner) alternation() (*gnode, error) {
+	var list []*gnode
+	for {
+		n, err := this.concatenation()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+		this.ws()
+		if this.at == len(this.s) || this.s[this.at] != '/' {
+			return gnodeOf(gAlt, list), nil
+		}
+		this.at++
+	}
+}
+
+func (this *abnfScanner) concatenation() (*gnode, error) {
+	var list []*gnode
+	for {
+		this.ws()
+		if this.at == len(this.s) || strings.ContainsRune("/)]", rune(this.s[this.at])) {
+			break
+		}
+		n, err := this.repetition()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	if len(list) == 0 {
+		return nil, this.errorf("expected an element")
+	}
+	return gnodeOf(gSeq, list), nil
+}
+
+func (this *abnfScanner) repetition() (*gnode, error) {
+	m := this.match(abnfRepeat)
+	n, err := this.element()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case m[0] == "":
+		return n, nil
+	case m[2] == "": // exactly N
+		ct, _ := strconv.Atoi(m[1])
+		return &gnode{kind: gRep, sub: []*gnode{n}, min: ct, max: ct}, nil
+	default:
+		rep := &gnode{kind: gRep, sub: []*gnode{n}, max: -1}
+		if m[1] != "" {
+			rep.min, _ = strconv.Atoi(m[1])
+		}
+		if m[3] != "" {
+			rep.max, _ = strconv.Atoi(m[3])
+		}
+		return rep, nil
+	}
+}
+
+func (this *abnfScanner) element() (*gnode, error) {
+	if this.at == len(this.s) {
+		return nil, this.errorf("expected an element")
+	}
+	switch c := this.s[this.at]; {
+	case c == '(' || c == '[':
+		this.at++
+		n, err := this.alternation()
+		if err != nil {
+			return nil, err
+		}
+		this.ws()
+		end := map[byte]byte{'(': ')', '[': ']'}[c]
+		if this.at == len(this.s) || this.s[this.at] != end {
+			return nil, this.errorf("expected `%c`", end)
+		}
+		this.at++
+		if c == '[' {
+			return &gnode{kind: gRep, sub: []*gnode{n}, min: 0, max: 1}, nil
+		}
+		return n, nil
+	case c == '<':
+		return nil, this.errorf("prose values are not supported")
+	}
+	if m := this.match(abnfName); m != nil {
+		return &gnode{kind: gRef, text: ruleName(m[0])}, nil
+	}
+	if m := this.match(abnfString); m != nil {
+		return &gnode{kind: gLit, text: m[2], fold: strings.ToLower(m[1]) != "%s"}, nil
+	}
+	if m := this.match(abnfNum); m != nil {
+		base := map[string]int{"x": 16, "d": 10, "b": 2}[strings.ToLower(m[1])]
+		num := func(s string) (rune, error) {
+			v, err := strconv.ParseInt(s, base, 32)
+			if err != nil {
+				return 0, this.errorf("invalid number %q: %v", s, err)
+			}
+			return rune(v), nil
+		}
+		first, err := num(m[2])
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasPrefix(m[3], "-"):
+			last, err := num(m[3][1:])
+			if err != nil {
+				return nil, err
+			}
+			return &gnode{kind: gRE, text: "[" + reRune(first, true) + "-" + reRune(last, true) + "]"}, nil
+		default:
+			re := reRune(first, false)
+			if m[3] != "" {
+				for _, s := range strings.Split(m[3][1:], ".") {
+					r, err := num(s)
+					if err != nil {
+						return nil, err
+					}
+					re += reRune(r, false)
+				}
+			}
+			return &gnode{kind: gRE, text: re}, nil
+		}
+	}
+	return nil, this.errorf("unexpected text")
+}
+
+// a rune as a regexp, escaped if needed
+func reRune(r rune, class bool) string {
+	switch {
+	case r <= ' ' || r >= 0x7f:
+		return fmt.Sprintf(`\x{%X}`, r)
+	case class && strings.ContainsRune(`\]^-[`, r):
+		return `\` + string(r)
+	case class:
+		return string(r)
+	default:
+		return regexp.QuoteMeta(string(r))
+	}
+}
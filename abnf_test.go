@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestImportABNF(t *testing.T) {
+	var g Grammar
+	err := g.ImportABNF("config.abnf", []byte(`
+; key/value pairs, one per line
+config  = *line
+line    = key *WSP "=" *WSP value CRLF
+key     = ALPHA *(ALPHA / DIGIT / "-")
+value   = 1*VCHAR
+`))
+	test.NoError(t, err)
+	test.NoError(t, g.Verify())
+	t.Logf("\n%s", g.Dump())
+
+	g.Alt("key").Prods()[0].Return(func(p Pos, _ string, _ any) string {
+		return p.Extract(0)
+	})
+	g.Alt("value").Prods()[0].Return(func(p Pos, _ []string) string {
+		return p.Extract(0)
+	})
+	g.Alt("line").Prods()[0].Return(func(k string, _ any, _ string, _ any, v string, _ any) [2]string {
+		return [2]string{k, v}
+	})
+	out, _, err := g.Parse("config", []byte("Name = Bob\r\nAge=42\r\n"))
+	test.NoError(t, err)
+	test.EqualsJSON(t, []any{[]string{"Name", "Bob"}, []string{"Age", "42"}}, out)
+
+	_, _, err = g.Parse("config", []byte("Name = Bob\n"))
+	test.Error(t, err) // CRLF is required
+}
+
+// the core rules are added once
+func TestImportABNFTwice(t *testing.T) {
+	var g Grammar
+	test.NoError(t, g.ImportABNF("a.abnf", []byte("word = 1*ALPHA\n")))
+	test.NoError(t, g.ImportABNF("b.abnf", []byte("name = ALPHA *(ALPHA / DIGIT)\n")))
+	test.NoError(t, g.Verify())
+	_, _, err := g.Parse("name", []byte("x1"))
+	test.NoError(t, err)
+}
+
+// a failed import leaves the grammar as it was
+func TestImportABNFFailed(t *testing.T) {
+	var g Grammar
+	test.NoError(t, g.ImportABNF("a.abnf", []byte("word = 1*ALPHA\n")))
+	dump := g.Dump()
+	err := g.ImportABNF("b.abnf", []byte("list = word *(\",\" [ \" \" ] word)\nword =/ DIGIT\nlist = \"x\"\n"))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "rule list already defined")
+	test.EqualsGo(t, dump, g.Dump())
+	test.NoError(t, g.Verify())
+}
+
+func TestImportABNFRepeat(t *testing.T) {
+	var g Grammar
+	test.NoError(t, g.ImportABNF("x.abnf", []byte(`
+code     = 2*3DIGIT [ "-" %s"x" ] %x2E.2E
+Code     =/ "none"
+hex      = "0x" 4HEXDIG
+`)))
+	test.NoError(t, g.Verify())
+	for _, s := range []string{"12..", "123..", "12-x..", "NONE"} {
+		_, _, err := g.Parse("code", []byte(s))
+		test.NoError(t, err)
+	}
+	for _, s := range []string{"1..", "1234..", "12-X..", "12."} {
+		_, _, err := g.Parse("code", []byte(s))
+		test.Error(t, err)
+	}
+	out, _, err := g.Parse("hex", []byte("0XbeeF"))
+	test.NoError(t, err)
+	test.EqualsJSON(t, []any{"0X", "b", "e", "e", "F"}, out)
+}
+
+func TestImportABNFErrors(t *testing.T) {
+	var g Grammar
+	err := g.ImportABNF("bad.abnf", []byte("a = \"x\"\nb = <prose>\n"))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "bad.abnf:2")
+
+	test.NoError(t, g.ImportABNF("a.abnf", []byte("a = \"x\"\n")))
+	err = g.ImportABNF("bad.abnf", []byte("a = \"y\"\n"))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "already defined")
+}
@@ -14,6 +14,12 @@ type Alts struct {
 	Name    string
 	prods   []*Prod
 	retType reflect.Type
+
+	// for alternations created by the directives: "rep" and "rep_" for `x(s)`, "opt" for `[ x ]`
+	internal string
+
+	// what the rule is called in the errors, see Label()
+	label string
 }
 
 // Add a production to the given list
@@ -21,28 +27,49 @@ func (this *Alts) Add(directives string, fn any) *Prod {
 	if this.Grammar.Log != nil {
 		this.Grammar.Log("adding %s: %s", this.Name, directives)
 	}
+	_, file, line, _ := runtime.Caller(1)
+	file = filepath.Base(file)
+	p, err := this.add(fmt.Sprintf("%s:%d", file, line), directives)
+	if err != nil {
+		log.Errorf(nil, "can't create prod %q: %v", this.Name, err)
+		panic(err)
+	}
+	if fn == nil {
+		return p
+	}
+	return p.Return(fn)
+}
+
+// name the rule in the errors: if it fails without matching anything, the error is `expected <name>` instead
+// of what its alternatives expected, and so is Stats.Expected
+func (this *Alts) Label(name string) *Alts {
+	this.label = name
+	return this
+}
+
+// the productions, in the order they are tried
+func (this *Alts) Prods() []*Prod {
+	return this.prods
+}
+
+// build and append a new production, src is where it was defined
+func (this *Alts) add(src, directives string) (*Prod, error) {
 	if this.Grammar.alts == nil {
 		this.Grammar.alts = map[string]*Alts{}
 	}
-	this.Grammar.Stats.Productions++
-	_, file, line, _ := runtime.Caller(1)
-	file = filepath.Base(file)
 	p := &Prod{
 		g:         this.Grammar,
 		Name:      this.Name,
 		Directive: directives,
-		src:       fmt.Sprintf("%s:%d", file, line),
+		src:       src,
 	}
 	_, err := p.build("")
 	if err != nil {
-		log.Errorf(nil, "can't create prod %q: %v", this.Name, err)
-		panic(err)
+		return nil, err
 	}
+	this.Grammar.Stats.Productions++
 	this.append(p)
-	if fn == nil {
-		return p
-	}
-	return p.Return(fn)
+	return p, nil
 }
 
 func (this *Alts) append(p *Prod) {
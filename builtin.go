@@ -0,0 +1,160 @@
+package parse
+
+import (
+	"strconv"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// builtin directives, in the form of `<name>` or `<name:arg>`
+// like terminals, they are checked after skipping the whitespaces
+// each returns a function that checks the position, and may advance it
+var builtins = map[string]func(prod *Prod, arg string) (func(p *pos) bool, error){
+	// end of input
+	"eof": func(prod *Prod, arg string) (func(p *pos) bool, error) {
+		if arg != "" {
+			return nil, ctx.NewErrorf(nil, "unexpected argument %q", arg)
+		}
+		return func(p *pos) bool {
+			return p.at == len(p.src.bytes)
+		}, nil
+	},
+
+	// beginning of a line
+	"bol": func(prod *Prod, arg string) (func(p *pos) bool, error) {
+		if arg != "" {
+			return nil, ctx.NewErrorf(nil, "unexpected argument %q", arg)
+		}
+		return func(p *pos) bool {
+			return p.at == 0 || p.src.bytes[p.at-1] == '\n'
+		}, nil
+	},
+
+	// end of a line (or of the input), doesn't consume the newline
+	"eol": func(prod *Prod, arg string) (func(p *pos) bool, error) {
+		if arg != "" {
+			return nil, ctx.NewErrorf(nil, "unexpected argument %q", arg)
+		}
+		return func(p *pos) bool {
+			rem := p.src.bytes[p.at:]
+			return len(rem) == 0 || rem[0] == '\n' || (len(rem) > 1 && rem[0] == '\r' && rem[1] == '\n')
+		}, nil
+	},
+
+	// always fails, with the given message as the error, e.g. `")" | <error:unclosed parenthesis>`
+	"error": func(prod *Prod, arg string) (func(p *pos) bool, error) {
+		if arg == "" {
+			return nil, ctx.NewErrorf(nil, "expected a message")
+		}
+		return func(p *pos) bool {
+			return false
+		}, nil
+	},
+
+	// 1-based column (in bytes)
+	"col": func(prod *Prod, arg string) (func(p *pos) bool, error) {
+		col, err := strconv.Atoi(arg)
+		if err != nil || col < 1 {
+			return nil, ctx.NewErrorf(nil, "expected a column number, got %q", arg)
+		}
+		return func(p *pos) bool {
+			return p.src.Col(p.at) == col
+		}, nil
+	},
+}
+
+func init() {
+	// the indentation is compared against a stack, which is restored on backtrack
+	// all of them skip blank lines and consume the leading whitespaces of the line
+	builtins["indent"] = newIndentCheck(func(p *pos, width int) bool {
+		if width <= p.indent() {
+			return false
+		}
+		l := len(p.indents)
+		p.indents = append(p.indents[0:l:l], width) // never share the backing array
+		return true
+	})
+	builtins["samedent"] = newIndentCheck(func(p *pos, width int) bool {
+		return width == p.indent()
+	})
+	builtins["dedent"] = newIndentCheck(func(p *pos, width int) bool {
+		if width >= p.indent() {
+			return false
+		}
+		p.indents = p.indents[0 : len(p.indents)-1]
+		return true
+	})
+}
+
+func newIndentCheck(cmp func(p *pos, width int) bool) func(prod *Prod, arg string) (func(p *pos) bool, error) {
+	return func(prod *Prod, arg string) (func(p *pos) bool, error) {
+		if arg != "" {
+			return nil, ctx.NewErrorf(nil, "unexpected argument %q", arg)
+		}
+		return func(p *pos) bool {
+			width, end, ok := p.indentation()
+			if !ok || !cmp(p, width) {
+				return false
+			}
+			p.at = end
+			return true
+		}, nil
+	}
+}
+
+// current indentation level
+func (this *pos) indent() int {
+	if len(this.indents) == 0 {
+		return 0
+	}
+	return this.indents[len(this.indents)-1]
+}
+
+// width of the leading whitespaces of the current line, skipping blank lines
+// returns false if the position is not inside the leading whitespaces
+func (this *pos) indentation() (width int, end int, ok bool) {
+	tab := this.g.TabWidth
+	if tab <= 0 {
+		tab = 8
+	}
+	src := this.src.bytes
+	at := this.at
+	if at == len(src) {
+		return 0, at, true // end of input closes all the blocks
+	}
+	start := at
+	for start > 0 && src[start-1] != '\n' {
+		start--
+	}
+	for {
+		width = 0
+		end = start
+		for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+			if src[end] == '\t' {
+				width += tab - width%tab
+			} else {
+				width++
+			}
+			end++
+		}
+		if at > end {
+			return 0, 0, false
+		}
+		if end == len(src) {
+			return 0, end, true // end of input closes all the blocks
+		}
+		switch src[end] {
+		case '\r', '\n': // blank line
+			for end < len(src) && src[end] != '\n' {
+				end++
+			}
+			if end == len(src) {
+				return 0, end, true
+			}
+			start = end + 1
+			at = start
+		default:
+			return width, end, true
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestBuiltinINI(t *testing.T) {
+	var g Grammar
+	if testing.Verbose() {
+		g.Log = t.Logf
+	}
+	ws := regexp.MustCompile(`^[ \t]*(;[^\n]*)?`)
+	g.Add("ini", `section(s) <eof>`).WS = ws
+	g.Add("section", `<bol> "[" name "]" ~nl [ pair(s) ]`).Return(func(name string, pairs []string) []string {
+		return append([]string{name}, pairs...)
+	}).WS = ws
+	g.Add("pair", `<bol> name "=" /[^\n;]*/ ~nl`).Return(func(k, v string) string {
+		return k + ":" + v
+	}).WS = ws
+	g.Add("name", `/\w+/`).WS = ws
+	g.Add("nl", `<eol> ~/\n*/`).WS = ws
+	test.NoError(t, g.Verify())
+
+	out, _, err := g.Parse("ini", []byte("[foo] ; first\na=1\nb=2\n\n[bar]\nc=3\n"))
+	test.NoError(t, err)
+	test.EqualsJSON(t, `[["foo","a:1","b:2"],["bar","c:3"]]`, out)
+
+	_, _, err = g.Parse("ini", []byte("[foo] a=1\n"))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "<eol>")
+}
+
+func TestBuiltinEOF(t *testing.T) {
+	var g Grammar
+	g.Add("main", `word(s) <eof>`)
+	g.Add("main", `word(s) "..."`)
+	g.Add("word", `/\w+/`).WS = Whitespaces
+	{
+		out, _, err := g.Parse("main", []byte("foo bar"))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `["foo","bar"]`, out)
+	}
+	{
+		out, _, err := g.Parse("main", []byte("foo bar..."))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `["foo","bar"]`, out)
+	}
+}
+
+func TestBuiltinCol(t *testing.T) {
+	var g Grammar
+	g.Add("main", `line(s)`)
+	g.Add("line", `<col:1> /\w+/ <col:5> /\w+/ /\n/`).WS = regexp.MustCompile(`^ *`)
+	{
+		out, _, err := g.Parse("main", []byte("foo bar\nxy  baz\n"))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `[["foo","bar","\n"],["xy","baz","\n"]]`, out)
+	}
+	{
+		_, _, err := g.Parse("main", []byte("foo  bar\n"))
+		test.Error(t, err)
+	}
+}
+
+func TestBuiltinInvalid(t *testing.T) {
+	for _, d := range []string{`<nope>`, `<col:x>`, `<eof:1>`} {
+		g := Grammar{}
+		p := Prod{g: &g, Directive: d}
+		_, err := p.build("")
+		t.Logf("%s: %v", d, err)
+		test.Error(t, err)
+	}
+}
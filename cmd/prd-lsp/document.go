@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+// an open document
+type document struct {
+	uri   string
+	text  []byte
+	lines []int // the offset where each line starts
+	tree  *parse.Tree
+}
+
+func (this *document) setText(text []byte) {
+	this.text = text
+	this.lines = []int{0}
+	for i, c := range text {
+		if c == '\n' {
+			this.lines = append(this.lines, i+1)
+		}
+	}
+}
+
+// the LSP position of the offset, where characters are UTF-16 code units
+func (this *document) position(at int) position {
+	line := sort.SearchInts(this.lines, at+1) - 1
+	char := 0
+	for _, r := range string(this.text[this.lines[line]:at]) {
+		char += utf16.RuneLen(r)
+	}
+	return position{line, char}
+}
+
+// the offset of the LSP position, clamped to the line
+func (this *document) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(this.lines) {
+		return len(this.text)
+	}
+	at := this.lines[p.Line]
+	for char := 0; char < p.Character && at < len(this.text) && this.text[at] != '\n'; {
+		r, n := utf8.DecodeRune(this.text[at:])
+		char += utf16.RuneLen(r)
+		at += n
+	}
+	return at
+}
@@ -0,0 +1,86 @@
+// Command prd-lsp is a Language Server Protocol server, over stdio, for any language defined by a grammar:
+//
+//	prd-lsp -grammar calc.prd -start expr -tokens num=number,op=operator -symbols def=function/name
+//
+// It provides:
+//   - diagnostics for the parse errors, reparsing only what changed (see parse.Tree)
+//   - semantic tokens from the highlight classes (see parse.Grammar.Highlight), which -tokens sets for some
+//     rules, rules named like a standard token type (`number`, `string`, `keyword`...) are used as they are
+//   - document symbols: a symbol for each node of the given rules, named after the first node of the
+//     rule after `/` (or the first terminal), nested like the nodes
+//   - completion of the terminals expected at the cursor (see parse.Grammar.CompletionsAt)
+//
+// Without -grammar the registered grammar is used (the `default_grammar` by default).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ohait/parse-rec-descent-go"
+	_ "github.com/ohait/parse-rec-descent-go/default_grammar"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("prd-lsp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	grammar := fs.String("grammar", "", "textual grammar (.prd) to use")
+	registered := fs.String("registered", "default", "registered grammar to use if -grammar is missing: "+strings.Join(parse.RegisteredNames(), ", "))
+	start := fs.String("start", "", "rule to start parsing from (default: the first one)")
+	tokens := fs.String("tokens", "", "semantic token type of the rules, as `rule=type,...`")
+	symbols := fs.String("symbols", "", "rules which are document symbols, as `rule=kind[/name rule],...`")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "prd-lsp: unexpected arguments %q\n", fs.Args())
+		return 2
+	}
+
+	g, err := load(*grammar, *registered)
+	if err != nil {
+		fmt.Fprintf(stderr, "prd-lsp: %v\n", err)
+		return 2
+	}
+	s, err := newServer(g, *start, *tokens, *symbols)
+	if err != nil {
+		fmt.Fprintf(stderr, "prd-lsp: %v\n", err)
+		return 2
+	}
+	s.out = stdout
+	s.log = stderr
+	return s.serve(stdin)
+}
+
+func load(file, registered string) (*parse.Grammar, error) {
+	var g *parse.Grammar
+	if file != "" {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		g, err = parse.LoadGrammar(file, text)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		g = parse.Registered(registered)
+		if g == nil {
+			return nil, fmt.Errorf("no grammar registered as %q", registered)
+		}
+	}
+	if err := g.Verify(); err != nil {
+		return nil, err
+	}
+	if len(g.Rules()) == 0 {
+		return nil, fmt.Errorf("empty grammar")
+	}
+	return g, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+const grammar = `%ws /\s*/
+program: [ def(s) ] <eof>
+def: "def" name "(" [ name(s ",") ] ")" "=" number ";"
+name: /[a-z]+/
+number: /\d+/
+`
+
+// a client session: the requests are sent all at once, then the responses are read back
+type session struct {
+	in bytes.Buffer
+	id int
+}
+
+func (this *session) notify(method string, params any) {
+	m := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	j, _ := json.Marshal(m)
+	fmt.Fprintf(&this.in, "Content-Length: %d\r\n\r\n%s", len(j), j)
+}
+
+func (this *session) request(method string, params any) int {
+	this.id++
+	m := map[string]any{"jsonrpc": "2.0", "id": this.id, "method": method, "params": params}
+	j, _ := json.Marshal(m)
+	fmt.Fprintf(&this.in, "Content-Length: %d\r\n\r\n%s", len(j), j)
+	return this.id
+}
+
+func read(t *testing.T, out io.Reader) []message {
+	var list []message
+	r := textproto.NewReader(bufio.NewReader(out))
+	for {
+		h, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			return list
+		}
+		test.NoError(t, err)
+		n, err := strconv.Atoi(h.Get("Content-Length"))
+		test.NoError(t, err)
+		body := make([]byte, n)
+		_, err = io.ReadFull(r.R, body)
+		test.NoError(t, err)
+		var m message
+		test.NoError(t, json.Unmarshal(body, &m))
+		list = append(list, m)
+	}
+}
+
+func TestServer(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "def.prd")
+	test.NoError(t, os.WriteFile(file, []byte(grammar), 0644))
+
+	doc := map[string]any{"uri": "file:///a.def"}
+	at := func(line, char int) map[string]any {
+		return map[string]any{"textDocument": doc, "position": map[string]any{"line": line, "character": char}}
+	}
+	var s session
+	initialize := s.request("initialize", map[string]any{})
+	s.notify("initialized", map[string]any{})
+	s.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{
+		"uri": "file:///a.def", "version": 1, "text": "def f(a, b) = 1;\ndef g() = 22;\n",
+	}})
+	tokens := s.request("textDocument/semanticTokens/full", map[string]any{"textDocument": doc})
+	symbols := s.request("textDocument/documentSymbol", map[string]any{"textDocument": doc})
+	s.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": "file:///a.def", "version": 2},
+		"contentChanges": []any{map[string]any{"text": "def f(a, b) = ;\ndef g() = 22;\n"}},
+	})
+	s.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": "file:///a.def", "version": 3},
+		"contentChanges": []any{map[string]any{"text": "def f(a) = 1;\nd"}},
+	})
+	keyword := s.request("textDocument/completion", at(1, 1))
+	s.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": "file:///a.def", "version": 4},
+		"contentChanges": []any{map[string]any{"text": "def f("}},
+	})
+	param := s.request("textDocument/completion", at(0, 6))
+	s.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": "file:///a.def", "version": 5},
+		"contentChanges": []any{map[string]any{"text": "dfe f() = 1;\n"}},
+	})
+	unknown := s.request("textDocument/hover", at(0, 0))
+	shutdown := s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out, stderr bytes.Buffer
+	code := run([]string{"-grammar", file, "-tokens", "name=variable", "-symbols", "def=function/name"}, &s.in, &out, &stderr)
+	test.EqualsGo(t, "", stderr.String())
+	test.EqualsGo(t, 0, code)
+
+	results := map[int]message{}
+	var diags []json.RawMessage
+	for _, m := range read(t, &out) {
+		switch {
+		case m.ID != nil:
+			id, _ := strconv.Atoi(string(*m.ID))
+			results[id] = m
+		case m.Method == "textDocument/publishDiagnostics":
+			diags = append(diags, m.Params)
+		}
+	}
+
+	var caps struct {
+		Capabilities struct {
+			SemanticTokensProvider struct {
+				Legend struct {
+					TokenTypes []string
+				}
+			}
+		}
+	}
+	test.NoError(t, json.Unmarshal(results[initialize].Result, &caps))
+	test.EqualsGo(t, tokenTypes, caps.Capabilities.SemanticTokensProvider.Legend.TokenTypes)
+
+	// `number` is a standard token type, `name` was given as a variable
+	test.EqualsJSON(t, `{"data":[
+		0,4,1,8,0, 0,2,1,8,0, 0,3,1,8,0, 0,5,1,19,0,
+		1,4,1,8,0, 0,6,2,19,0
+	]}`, results[tokens].Result)
+
+	test.EqualsJSON(t, `[
+		{"name":"f","detail":"def","kind":12,
+			"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":16}},
+			"selectionRange":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}}},
+		{"name":"g","detail":"def","kind":12,
+			"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":13}},
+			"selectionRange":{"start":{"line":1,"character":4},"end":{"line":1,"character":5}}}
+	]`, results[symbols].Result)
+
+	test.EqualsGo(t, 5, len(diags))
+	test.EqualsJSON(t, `{"uri":"file:///a.def","version":1,"diagnostics":[]}`, diags[0])
+	test.EqualsJSON(t, `{"uri":"file:///a.def","version":2,"diagnostics":[{
+		"range":{"start":{"line":0,"character":14},"end":{"line":0,"character":15}},
+		"severity":1,"source":"prd","message":"expected /\\d+/"
+	}]}`, diags[1])
+	test.EqualsJSON(t, `{"uri":"file:///a.def","version":5,"diagnostics":[{
+		"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":3}},
+		"severity":1,"source":"prd","message":"expected \"def\", <eof>, did you mean \"def\"?"
+	}]}`, diags[4])
+
+	test.EqualsJSON(t, `[{"label":"def","kind":14,"detail":"def",
+		"textEdit":{"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":1}},"newText":"def"}
+	}]`, results[keyword].Result)
+	test.EqualsJSON(t, `[
+		{"label":"name","kind":1,"detail":"/[a-z]+/"},
+		{"label":")","kind":24,"detail":"def",
+			"textEdit":{"range":{"start":{"line":0,"character":6},"end":{"line":0,"character":6}},"newText":")"}}
+	]`, results[param].Result)
+
+	test.EqualsJSON(t, `{"code":-32601,"message":"method not found: \"textDocument/hover\""}`, results[unknown].Error)
+	test.EqualsJSON(t, `null`, results[shutdown].Result)
+}
+
+func TestPosition(t *testing.T) {
+	var d document
+	d.setText([]byte("aé𝄞b\n\nx"))
+	for _, c := range []struct {
+		at   int
+		line int
+		char int
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{3, 0, 2},  // é is 2 bytes, 1 UTF-16 unit
+		{7, 0, 4},  // 𝄞 is 4 bytes, 2 UTF-16 units
+		{8, 0, 5},  // the newline
+		{9, 1, 0},  // empty line
+		{10, 2, 0}, // x
+		{11, 2, 1}, // the end
+	} {
+		p := d.position(c.at)
+		test.EqualsGo(t, position{c.line, c.char}, p)
+		test.EqualsGo(t, c.at, d.offset(p))
+	}
+	test.EqualsGo(t, 8, d.offset(position{0, 99})) // clamped to the line
+	test.EqualsGo(t, 11, d.offset(position{5, 0}))
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+// the standard semantic token types, in the order of the legend
+var tokenTypes = []string{
+	"namespace", "type", "class", "enum", "interface", "struct", "typeParameter", "parameter", "variable",
+	"property", "enumMember", "event", "function", "method", "macro", "keyword", "modifier", "comment",
+	"string", "number", "regexp", "operator",
+}
+
+var symbolKinds = map[string]int{
+	"file": 1, "module": 2, "namespace": 3, "package": 4, "class": 5, "method": 6, "property": 7, "field": 8,
+	"constructor": 9, "enum": 10, "interface": 11, "function": 12, "variable": 13, "constant": 14, "string": 15,
+	"number": 16, "boolean": 17, "array": 18, "object": 19, "key": 20, "null": 21, "enumMember": 22, "struct": 23,
+	"event": 24, "operator": 25, "typeParameter": 26,
+}
+
+type server struct {
+	g       *parse.Grammar
+	start   string
+	symbols map[string]symbolRule
+	docs    map[string]*document
+
+	out      io.Writer
+	log      io.Writer
+	shutdown bool
+}
+
+type symbolRule struct {
+	kind int
+	name string // the rule of the node which names the symbol
+}
+
+func newServer(g *parse.Grammar, start, tokens, symbols string) (*server, error) {
+	s := &server{
+		g:       g,
+		start:   start,
+		symbols: map[string]symbolRule{},
+		docs:    map[string]*document{},
+	}
+	if s.start == "" {
+		s.start = g.Rules()[0]
+	}
+	if len(g.Alt(s.start).Prods()) == 0 {
+		return nil, fmt.Errorf("no rule named %q, rules: %s", s.start, strings.Join(g.Rules(), ", "))
+	}
+	// the semantic tokens are the highlight classes of the productions
+	rules := g.Rules()
+	class := func(rule, typ string) {
+		for _, p := range g.Alt(rule).Prods() {
+			p.Class(typ)
+		}
+	}
+	for _, t := range tokenTypes {
+		if slices.Contains(rules, t) {
+			class(t, t)
+		}
+	}
+	err := pairs(tokens, func(rule, typ string) error {
+		if !slices.Contains(tokenTypes, typ) {
+			return fmt.Errorf("unknown token type %q, use one of: %s", typ, strings.Join(tokenTypes, ", "))
+		}
+		if !slices.Contains(rules, rule) {
+			return fmt.Errorf("no rule named %q", rule)
+		}
+		class(rule, typ)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = pairs(symbols, func(rule, kind string) error {
+		kind, name, _ := strings.Cut(kind, "/")
+		k := symbolKinds[kind]
+		if k == 0 {
+			return fmt.Errorf("unknown symbol kind %q", kind)
+		}
+		s.symbols[rule] = symbolRule{k, name}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parse `a=b,c=d`
+func pairs(s string, f func(k, v string) error) error {
+	if s == "" {
+		return nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("expected `rule=value`, got %q", kv)
+		}
+		if err := f(strings.TrimSpace(k), strings.TrimSpace(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// read the requests until `exit`, returns the exit code
+func (this *server) serve(in io.Reader) int {
+	r := textproto.NewReader(bufio.NewReader(in))
+	for {
+		h, err := r.ReadMIMEHeader()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(this.log, "prd-lsp: %v\n", err)
+			}
+			return 1
+		}
+		n, err := strconv.Atoi(h.Get("Content-Length"))
+		if err != nil {
+			fmt.Fprintf(this.log, "prd-lsp: bad Content-Length: %v\n", err)
+			return 1
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r.R, body); err != nil {
+			fmt.Fprintf(this.log, "prd-lsp: %v\n", err)
+			return 1
+		}
+		var m message
+		if err := json.Unmarshal(body, &m); err != nil {
+			fmt.Fprintf(this.log, "prd-lsp: %v\n", err)
+			continue
+		}
+		if m.Method == "exit" {
+			if this.shutdown {
+				return 0
+			}
+			return 1
+		}
+		out, err := this.handle(m.Method, m.Params)
+		if m.ID == nil {
+			if err != nil {
+				fmt.Fprintf(this.log, "prd-lsp: %s: %v\n", m.Method, err)
+			}
+			continue
+		}
+		resp := message{JSONRPC: "2.0", ID: m.ID}
+		var rerr *rpcError
+		switch {
+		case errors.As(err, &rerr):
+			resp.Error = rerr
+		case err != nil:
+			resp.Error = &rpcError{-32603, err.Error()}
+		default:
+			resp.Result, _ = json.Marshal(out)
+		}
+		this.send(resp)
+	}
+}
+
+func (this *rpcError) Error() string { return this.Message }
+
+func (this *server) send(m message) {
+	j, err := json.Marshal(m)
+	if err != nil {
+		fmt.Fprintf(this.log, "prd-lsp: %v\n", err)
+		return
+	}
+	fmt.Fprintf(this.out, "Content-Length: %d\r\n\r\n%s", len(j), j)
+}
+
+func (this *server) notify(method string, params any) {
+	j, _ := json.Marshal(params)
+	this.send(message{JSONRPC: "2.0", Method: method, Params: j})
+}
+
+type textDocument struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type span struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+func (this *server) handle(method string, params json.RawMessage) (any, error) {
+	var p struct {
+		TextDocument   textDocument `json:"textDocument"`
+		Position       position     `json:"position"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{-32602, err.Error()}
+		}
+	}
+	switch method {
+	case "initialize":
+		return this.initialize(), nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		this.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		d := &document{uri: p.TextDocument.URI}
+		this.docs[d.uri] = d
+		this.update(d, p.TextDocument.Version, p.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		d := this.docs[p.TextDocument.URI]
+		if d == nil || len(p.ContentChanges) == 0 {
+			return nil, fmt.Errorf("unknown document %q", p.TextDocument.URI)
+		}
+		this.update(d, p.TextDocument.Version, p.ContentChanges[len(p.ContentChanges)-1].Text)
+		return nil, nil
+	case "textDocument/didClose":
+		delete(this.docs, p.TextDocument.URI)
+		this.notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         p.TextDocument.URI,
+			"diagnostics": []any{},
+		})
+		return nil, nil
+	case "textDocument/semanticTokens/full":
+		d, err := this.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"data": this.semanticTokens(d)}, nil
+	case "textDocument/documentSymbol":
+		d, err := this.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return this.documentSymbols(d), nil
+	case "textDocument/completion":
+		d, err := this.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return this.completion(d, p.Position)
+	}
+	return nil, &rpcError{-32601, fmt.Sprintf("method not found: %q", method)}
+}
+
+func (this *server) doc(uri string) (*document, error) {
+	d := this.docs[uri]
+	if d == nil {
+		return nil, &rpcError{-32602, fmt.Sprintf("unknown document %q", uri)}
+	}
+	return d, nil
+}
+
+func (this *server) initialize() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync":       1, // full
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]any{},
+			"semanticTokensProvider": map[string]any{
+				"legend": map[string]any{
+					"tokenTypes":     tokenTypes,
+					"tokenModifiers": []string{},
+				},
+				"full": true,
+			},
+		},
+		"serverInfo": map[string]any{"name": "prd-lsp"},
+	}
+}
+
+// set the new text, reparse it and publish the errors
+func (this *server) update(d *document, version int, text string) {
+	if d.tree == nil {
+		d.tree, _ = this.g.ParseTree(this.start, d.uri, nil)
+	}
+	// the editor sends the whole text, find what changed
+	old, now := d.text, []byte(text)
+	pre := 0
+	for pre < len(old) && pre < len(now) && old[pre] == now[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(old)-pre && suf < len(now)-pre && old[len(old)-1-suf] == now[len(now)-1-suf] {
+		suf++
+	}
+	d.tree.Edit(pre, len(old)-suf, len(now)-suf)
+	d.setText(now)
+	_, stats, err := d.tree.Reparse(now)
+
+	diags := []any{}
+	if err != nil {
+		at := stats.Farthest
+		var perr *parse.Error
+		if errors.As(err, &perr) {
+			at = max(at, perr.At())
+		}
+		msg := ""
+		switch {
+		case at == stats.Farthest && len(stats.Expected) > 0:
+			msg = "expected " + strings.Join(stats.Expected, ", ")
+		case perr != nil:
+			msg = errors.Unwrap(perr).Error()
+		default:
+			msg = err.Error()
+		}
+		if perr != nil && len(perr.Suggestions) > 0 {
+			msg += ", " + perr.Hint()
+		}
+		// up to the end of the word
+		end := at + len(word.Find(now[at:]))
+		diags = append(diags, map[string]any{
+			"range":    span{d.position(at), d.position(end)},
+			"severity": 1,
+			"source":   "prd",
+			"message":  msg,
+		})
+	}
+	this.notify("textDocument/publishDiagnostics", map[string]any{
+		"uri":         d.uri,
+		"version":     version,
+		"diagnostics": diags,
+	})
+}
+
+var word = regexp.MustCompile(`^[^\s]*`)
+
+// the highlight classes which are semantic token types
+func (this *server) semanticTokens(d *document) []int {
+	h, err := this.g.Highlight(this.start, d.text)
+	if err != nil {
+		return []int{}
+	}
+	return h.SemanticTokens(tokenTypes)
+}
+
+type documentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          span             `json:"range"`
+	SelectionRange span             `json:"selectionRange"`
+	Children       []documentSymbol `json:"children,omitempty"`
+}
+
+func (this *server) documentSymbols(d *document) []documentSymbol {
+	out := []documentSymbol{}
+	n, _, err := this.g.ParseCST(this.start, d.uri, d.text)
+	if err != nil {
+		return out
+	}
+	var walk func(n *parse.Node) []documentSymbol
+	walk = func(n *parse.Node) []documentSymbol {
+		var kids []documentSymbol
+		for _, c := range n.Children {
+			kids = append(kids, walk(c)...)
+		}
+		sym, ok := this.symbols[n.Rule]
+		if !ok || n.Rule == "" {
+			return kids
+		}
+		name := first(n, sym.name)
+		if name == nil {
+			name = n
+		}
+		text, _, _ := strings.Cut(string(d.text[name.Pos.From:name.Pos.End]), "\n")
+		return []documentSymbol{{
+			Name:           text,
+			Detail:         n.Rule,
+			Kind:           sym.kind,
+			Range:          span{d.position(n.Pos.From), d.position(n.Pos.End)},
+			SelectionRange: span{d.position(name.Pos.From), d.position(name.Pos.From + len(text))},
+			Children:       kids,
+		}}
+	}
+	return append(out, walk(n)...)
+}
+
+// the first node of the given rule, or the first terminal if rule is empty
+func first(n *parse.Node, rule string) *parse.Node {
+	for _, c := range n.Children {
+		if c.Rule == rule {
+			return c
+		}
+		if f := first(c, rule); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+type completionItem struct {
+	Label    string    `json:"label"`
+	Kind     int       `json:"kind,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	TextEdit *textEdit `json:"textEdit,omitempty"`
+}
+
+type textEdit struct {
+	Range   span   `json:"range"`
+	NewText string `json:"newText"`
+}
+
+var keyword = regexp.MustCompile(`^\w+$`)
+
+// literals are inserted (replacing what was typed already), the other terminals are only listed
+func (this *server) completion(d *document, at position) (any, error) {
+	cursor := d.offset(at)
+	list, err := this.g.CompletionsAt(this.start, d.text, cursor)
+	if err != nil {
+		return nil, err
+	}
+	items := []completionItem{}
+	seen := map[string]bool{}
+	for _, c := range list {
+		item := completionItem{
+			Label:  c.Text,
+			Kind:   14, // keyword
+			Detail: c.Rule,
+			TextEdit: &textEdit{
+				Range:   span{d.position(c.From), at},
+				NewText: c.Text,
+			},
+		}
+		switch {
+		case c.Text == "":
+			item = completionItem{
+				Label:  c.Rule,
+				Kind:   1, // text
+				Detail: c.Expected,
+			}
+			if c.Rule == "" {
+				item.Label = c.Expected
+			}
+		case !keyword.MatchString(c.Text):
+			item.Kind = 24 // operator
+		}
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,190 @@
+// Command prd parses files (or stdin) with a grammar and prints the resulting tree.
+//
+//	prd -grammar calc.prd -start expr input.txt
+//	echo '1+2*3' | prd -format text
+//	prd -registered default -start expr -trace input.txt
+//	prd repl calc.prd
+//
+// Without -grammar the registered grammar is used (the `default_grammar` by default).
+// Parse errors are printed as `file:line:col: message` and the exit code is 1, it's 2 for a wrong usage or grammar.
+//
+// `prd repl` starts an interactive session to develop a grammar, see `:help`.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ohait/parse-rec-descent-go"
+	_ "github.com/ohait/parse-rec-descent-go/default_grammar"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type options struct {
+	grammar    string
+	registered string
+	start      string
+	format     string
+	trace      bool
+	traceJSON  bool
+	color      bool
+	cover      string
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "repl" {
+		return repl(args[1:], stdin, stdout, stderr)
+	}
+	var opts options
+	fs := flag.NewFlagSet("prd", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.grammar, "grammar", "", "textual grammar (.prd) to use")
+	fs.StringVar(&opts.registered, "registered", "default", "registered grammar to use if -grammar is missing: "+strings.Join(parse.RegisteredNames(), ", "))
+	fs.StringVar(&opts.start, "start", "", "rule to start parsing from (default: the first one)")
+	fs.StringVar(&opts.format, "format", "json", "output format: json, text or none")
+	fs.BoolVar(&opts.trace, "trace", false, "print the parse trace on stderr")
+	fs.BoolVar(&opts.traceJSON, "trace-json", false, "print the parse trace on stderr as JSON Lines")
+	fs.BoolVar(&opts.color, "color", isTerminal(stderr), "use colours in the trace")
+	fs.StringVar(&opts.cover, "cover", "", "print which productions matched on stderr, and write an HTML view to the given file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	g, err := load(&opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "prd: %v\n", err)
+		return 2
+	}
+	var tracers parse.MultiTracer
+	switch {
+	case opts.traceJSON:
+		tracers = append(tracers, &parse.JSONTracer{W: stderr})
+	case opts.trace:
+		tracers = append(tracers, &parse.TextTracer{W: stderr, Color: opts.color})
+	}
+	cov := &parse.Coverage{}
+	if opts.cover != "" {
+		tracers = append(tracers, cov)
+	}
+	if len(tracers) > 0 {
+		g.Tracer = tracers
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	code := 0
+	for _, file := range files {
+		if err := parseFile(g, opts, file, stdin, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+		}
+	}
+	if opts.cover != "" {
+		r := cov.Report(g)
+		fmt.Fprint(stderr, r)
+		if err := os.WriteFile(opts.cover, []byte(r.HTML()), 0644); err != nil {
+			fmt.Fprintf(stderr, "prd: %v\n", err)
+			return 2
+		}
+	}
+	return code
+}
+
+func load(opts *options) (*parse.Grammar, error) {
+	var g *parse.Grammar
+	if opts.grammar != "" {
+		text, err := os.ReadFile(opts.grammar)
+		if err != nil {
+			return nil, err
+		}
+		g, err = parse.LoadGrammar(opts.grammar, text)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		g = parse.Registered(opts.registered)
+		if g == nil {
+			return nil, fmt.Errorf("no grammar registered as %q", opts.registered)
+		}
+	}
+	if err := g.Verify(); err != nil {
+		return nil, err
+	}
+	if len(g.Rules()) == 0 {
+		return nil, fmt.Errorf("empty grammar")
+	}
+	if opts.start == "" {
+		opts.start = g.Rules()[0]
+	}
+	if len(g.Alt(opts.start).Prods()) == 0 {
+		return nil, fmt.Errorf("no rule named %q, rules: %s", opts.start, strings.Join(g.Rules(), ", "))
+	}
+	switch opts.format {
+	case "json", "text", "none":
+	default:
+		return nil, fmt.Errorf("unknown format %q", opts.format)
+	}
+	return g, nil
+}
+
+func parseFile(g *parse.Grammar, opts options, file string, stdin io.Reader, stdout io.Writer) error {
+	var text []byte
+	var err error
+	if file == "-" {
+		file = "<stdin>"
+		text, err = io.ReadAll(stdin)
+	} else {
+		text, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	out, _, err := g.ParseFile(opts.start, file, text)
+	if err != nil {
+		return located(file, text, err)
+	}
+	switch opts.format {
+	case "json":
+		j, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s\n", j)
+	case "text":
+		s, err := parse.Indented(out)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, s)
+	}
+	return nil
+}
+
+// prefix the error with `file:line:col`, if it has an offset
+func located(file string, text []byte, err error) error {
+	var perr *parse.Error
+	if !errors.As(err, &perr) {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	line, col := parse.NewPos(file, text).Src.LineCol(perr.At())
+	return fmt.Errorf("%s:%d:%d: %v", file, line, col, errors.Unwrap(perr))
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
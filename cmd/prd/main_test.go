@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestRun(t *testing.T) {
+	prd := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, out, _ := prd("1+2*3", "-format", "text")
+	test.EqualsGo(t, 0, code)
+	test.EqualsGo(t, "left: \"1\"\nop: \"+\"\nright:\n  left: \"2\"\n  op: \"*\"\n  right: \"3\"\n", out)
+
+	code, out, _ = prd("(7)")
+	test.EqualsGo(t, 0, code)
+	test.EqualsGo(t, "\"7\"\n", out)
+
+	code, _, trace := prd("1", "-trace", "-format", "none")
+	test.EqualsGo(t, 0, code)
+	test.Contains(t, trace, "  consume factor @0-1 /\\d+/ \"1\"\n")
+	test.Assert(t, !strings.Contains(trace, "\033["))
+
+	dir := t.TempDir()
+	grammar := filepath.Join(dir, "list.prd")
+	test.NoError(t, os.WriteFile(grammar, []byte("%ws /\\s*/\nlist: pair(s) <eof>\npair: /[a-z]+/ + \"=\" /\\d+/\n"), 0644))
+	in := filepath.Join(dir, "in.txt")
+	test.NoError(t, os.WriteFile(in, []byte("a=1\nb 2\n"), 0644))
+
+	code, _, stderr := prd("", "-grammar", grammar, in)
+	test.EqualsGo(t, 1, code)
+	test.EqualsGo(t, in+":2:3: expected /^=/ got \"2\\n\"\n", stderr)
+
+	code, out, _ = prd("a=1 b=2", "-grammar", grammar, "-start", "list", "-format", "text")
+	test.EqualsGo(t, 0, code)
+	test.EqualsGo(t, "-\n  - \"a\"\n  - \"1\"\n-\n  - \"b\"\n  - \"2\"\n", out)
+
+	html := filepath.Join(dir, "cover.html")
+	code, _, stderr = prd("a=1", "-grammar", grammar, "-cover", html, "-format", "none")
+	test.EqualsGo(t, 0, code)
+	test.Contains(t, stderr, "total:")
+	_, err := os.Stat(html)
+	test.NoError(t, err)
+
+	code, _, stderr = prd("", "-grammar", grammar, "-start", "nope")
+	test.EqualsGo(t, 2, code)
+	test.Contains(t, stderr, `no rule named "nope", rules: list, pair`)
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+const replHelp = `rule: directive   (re)define a rule
+| directive        add an alternative to the last rule
+%pragma            add a pragma, like %ws /\s*/
+> text             parse the text, also any line which isn't a rule or a command
+text \             continue the input on the next line
+!!  !N             parse again the last input, or the Nth in :history
+:start [rule]      show or change the start rule
+:rules             list the rules
+:dump              print the grammar
+:lint              check the grammar for common mistakes
+:trace             toggle the parse trace
+:history           list the previous inputs
+:quit
+`
+
+var replRule = regexp.MustCompile(`^(\w+)\s*:`)
+
+// a piece of the grammar source: a rule with its alternatives, or a pragma
+type chunk struct {
+	rule  string
+	lines []string
+}
+
+type session struct {
+	file    string
+	chunks  []chunk
+	last    string // the last rule defined, `| directive` adds to it
+	g       *parse.Grammar
+	start   string
+	trace   bool
+	color   bool
+	inputs  []string
+	history io.Writer // if set, the inputs are appended here
+
+	out io.Writer
+}
+
+func repl(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("prd repl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	start := fs.String("start", "", "rule to start parsing from (default: the first one)")
+	histFile := fs.String("history", defaultHistory(), "file to keep the inputs in, empty to disable")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	s := &session{
+		file:  "<repl>",
+		start: *start,
+		color: isTerminal(stdout),
+		out:   stdout,
+	}
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		s.file = fs.Arg(0)
+		text, err := os.ReadFile(s.file)
+		if err != nil {
+			fmt.Fprintf(stderr, "prd: %v\n", err)
+			return 2
+		}
+		s.chunks = chunks(string(text))
+	default:
+		fmt.Fprintf(stderr, "prd: usage: prd repl [-start rule] [grammar.prd]\n")
+		return 2
+	}
+	if err := s.rebuild(); err != nil {
+		fmt.Fprintf(stderr, "prd: %v\n", err)
+		return 2
+	}
+	if *histFile != "" {
+		if text, err := os.ReadFile(*histFile); err == nil {
+			for _, line := range strings.Split(string(text), "\n") {
+				if line != "" {
+					s.inputs = append(s.inputs, line)
+				}
+			}
+		}
+		if f, err := os.OpenFile(*histFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+			defer f.Close()
+			s.history = f
+		}
+	}
+
+	in := bufio.NewScanner(stdin)
+	buf := ""
+	for {
+		if buf == "" {
+			fmt.Fprintf(stdout, "%s> ", s.start)
+		} else {
+			fmt.Fprintf(stdout, "%s| ", strings.Repeat(" ", len(s.start)))
+		}
+		if !in.Scan() {
+			fmt.Fprintln(stdout)
+			return 0
+		}
+		line := in.Text()
+		if strings.HasSuffix(line, `\`) {
+			buf += strings.TrimSuffix(line, `\`) + "\n"
+			continue
+		}
+		line, buf = buf+line, ""
+		if !s.exec(line) {
+			return 0
+		}
+	}
+}
+
+// execute a line, returns false to quit
+func (this *session) exec(line string) bool {
+	trim := strings.TrimSpace(line)
+	switch {
+	case trim == "":
+	case trim == ":quit" || trim == ":q":
+		return false
+	case strings.HasPrefix(trim, ":"):
+		this.command(trim[1:])
+	case strings.HasPrefix(trim, "!"):
+		n := len(this.inputs)
+		if trim != "!!" {
+			var err error
+			n, err = strconv.Atoi(trim[1:])
+			if err != nil {
+				this.printf("expected `!!` or `!N`\n")
+				return true
+			}
+		}
+		if n < 1 || n > len(this.inputs) {
+			this.printf("no input %d in the history\n", n)
+			return true
+		}
+		in := this.inputs[n-1]
+		this.printf("> %s\n", in)
+		this.parse(unescape(in))
+	case strings.HasPrefix(trim, "|"):
+		if this.last == "" {
+			this.printf("no rule to add an alternative to\n")
+			return true
+		}
+		this.define(this.last, "    "+trim, true)
+	case replRule.MatchString(trim):
+		this.define(replRule.FindStringSubmatch(trim)[1], trim, false)
+	case strings.HasPrefix(trim, "%"):
+		old := this.chunks
+		this.chunks = append(this.chunks, chunk{"", []string{trim}})
+		if err := this.rebuild(); err != nil {
+			this.chunks = old
+			this.printf("%v\n", err)
+		}
+	default:
+		text := line
+		if strings.HasPrefix(trim, ">") {
+			text = strings.TrimPrefix(strings.TrimLeft(line, " \t")[1:], " ")
+		}
+		this.remember(text)
+		this.parse(text)
+	}
+	return true
+}
+
+func (this *session) printf(f string, args ...any) {
+	fmt.Fprintf(this.out, f, args...)
+}
+
+func (this *session) command(cmd string) {
+	name, arg, _ := strings.Cut(cmd, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "help", "h":
+		this.printf("%s", replHelp)
+	case "start":
+		if arg == "" {
+			this.printf("%s\n", this.start)
+			return
+		}
+		if len(this.g.Alt(arg).Prods()) == 0 {
+			this.printf("no rule named %q, rules: %s\n", arg, strings.Join(this.g.Rules(), ", "))
+			return
+		}
+		this.start = arg
+	case "rules":
+		this.printf("%s\n", strings.Join(this.g.Rules(), " "))
+	case "dump":
+		this.printf("%s", this.source())
+	case "lint":
+		lints := this.g.Lint(this.start)
+		for _, l := range lints {
+			this.printf("%s\n", l)
+		}
+		if len(lints) == 0 {
+			this.printf("ok\n")
+		}
+	case "trace":
+		this.trace = !this.trace
+		this.setTracer()
+		this.printf("trace %v\n", map[bool]string{true: "on", false: "off"}[this.trace])
+	case "history":
+		from := max(0, len(this.inputs)-20)
+		for i := from; i < len(this.inputs); i++ {
+			this.printf("%4d  %s\n", i+1, this.inputs[i])
+		}
+	default:
+		this.printf("unknown command :%s, try :help\n", name)
+	}
+}
+
+// replace the rule with the given definition, or add an alternative to it
+func (this *session) define(rule, line string, alt bool) {
+	old := this.chunks
+	var chunks []chunk
+	found := -1
+	for _, c := range this.chunks {
+		switch {
+		case c.rule != rule:
+			chunks = append(chunks, c)
+		case alt:
+			found = len(chunks)
+			chunks = append(chunks, chunk{rule, append([]string{}, c.lines...)})
+		case found < 0:
+			found = len(chunks)
+			chunks = append(chunks, chunk{rule, []string{line}})
+		}
+	}
+	switch {
+	case found < 0:
+		chunks = append(chunks, chunk{rule, []string{line}})
+	case alt:
+		chunks[found].lines = append(chunks[found].lines, line)
+	}
+	this.chunks = chunks
+	if err := this.rebuild(); err != nil {
+		this.chunks = old
+		this.printf("%v\n", err)
+		return
+	}
+	this.last = rule
+}
+
+// the grammar source
+func (this *session) source() string {
+	var sb strings.Builder
+	for _, c := range this.chunks {
+		for _, l := range c.lines {
+			sb.WriteString(l)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// load the grammar from the chunks
+func (this *session) rebuild() error {
+	g, err := parse.LoadGrammar(this.file, []byte(this.source()))
+	if err != nil {
+		return err
+	}
+	if err := g.Verify(); err != nil {
+		// likely a rule not defined yet
+		this.printf("warning: %v\n", err)
+	}
+	this.g = g
+	this.setTracer()
+	if len(g.Alt(this.start).Prods()) == 0 && len(g.Rules()) > 0 {
+		this.start = g.Rules()[0]
+	}
+	return nil
+}
+
+func (this *session) setTracer() {
+	this.g.Tracer = nil
+	if this.trace {
+		this.g.Tracer = &parse.TextTracer{W: this.out, Color: this.color}
+	}
+}
+
+func (this *session) remember(text string) {
+	in := strings.ReplaceAll(text, "\n", `\n`)
+	this.inputs = append(this.inputs, in)
+	if this.history != nil {
+		fmt.Fprintln(this.history, in)
+	}
+}
+
+// parse the text and print the tree, or where it failed and what was expected there
+func (this *session) parse(text string) {
+	if this.start == "" {
+		this.printf("no rules yet, define one with `rule: directive`\n")
+		return
+	}
+	out, stats, err := this.g.ParseFile(this.start, "", []byte(text))
+	if err == nil {
+		s, err := parse.Indented(out)
+		if err != nil {
+			this.printf("%v\n", err)
+			return
+		}
+		this.printf("%s", s)
+		return
+	}
+	at := stats.Farthest
+	var perr *parse.Error
+	if errors.As(err, &perr) {
+		at = max(at, perr.At())
+	}
+	src := parse.NewPos("", []byte(text)).Src
+	line, col := src.LineCol(at)
+	lines := strings.Split(text, "\n")
+	if line > 0 && line <= len(lines) {
+		this.printf("  %s\n  %s^\n", lines[line-1], caretPad(lines[line-1], col))
+	}
+	if at == stats.Farthest && len(stats.Expected) > 0 {
+		this.printf("%d:%d: expected %s\n", line, col, strings.Join(stats.Expected, ", "))
+		if perr == nil || perr.At() == at {
+			this.suggest(perr)
+			return
+		}
+	}
+	if perr != nil {
+		// the error can be reported before the farthest position, e.g. after backtracking
+		line, col = src.LineCol(perr.At())
+		err = errors.Unwrap(perr)
+	}
+	this.printf("%d:%d: %v\n", line, col, err)
+	this.suggest(perr)
+}
+
+// print the keywords which might have been meant, see parse.Error.Suggestions
+func (this *session) suggest(perr *parse.Error) {
+	if perr != nil && len(perr.Suggestions) > 0 {
+		this.printf("%s\n", perr.Hint())
+	}
+}
+
+// whitespace to put under the text before the given 1-based column, keeping the tabs
+func caretPad(line string, col int) string {
+	var sb strings.Builder
+	for i, r := range line {
+		if i >= col-1 {
+			break
+		}
+		if r == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+	for sb.Len() < col-1 {
+		sb.WriteRune(' ') // past the end of the line
+	}
+	return sb.String()
+}
+
+// split the grammar source into rules and pragmas
+func chunks(text string) []chunk {
+	var out []chunk
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trim := strings.TrimSpace(line)
+		switch {
+		case trim == "" || strings.HasPrefix(trim, "#"):
+		case replRule.MatchString(line):
+			out = append(out, chunk{replRule.FindStringSubmatch(line)[1], []string{line}})
+		case strings.HasPrefix(trim, "|") && len(out) > 0 && out[len(out)-1].rule != "":
+			last := &out[len(out)-1]
+			last.lines = append(last.lines, line)
+		default:
+			out = append(out, chunk{"", []string{line}})
+		}
+	}
+	return out
+}
+
+// undo the escaping of the newlines in the history
+func unescape(in string) string {
+	return strings.ReplaceAll(in, `\n`, "\n")
+}
+
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".prd_history")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestRepl(t *testing.T) {
+	hist := filepath.Join(t.TempDir(), "history")
+	session := func(lines ...string) string {
+		var stdout, stderr bytes.Buffer
+		code := repl([]string{"-history", hist}, strings.NewReader(strings.Join(lines, "\n")+"\n"), &stdout, &stderr)
+		test.EqualsGo(t, 0, code)
+		test.EqualsGo(t, "", stderr.String())
+		return stdout.String()
+	}
+
+	out := session(
+		`%ws /\s*/`,
+		`list: "[" num(s ",") "]"`,
+		`num: /\d+/`,
+		`[1, 2]`,
+		`[1, 2 3]`,
+		`num: <nope>`,
+		`num: "nil"`,
+		`| /\d+/`,
+		`[nul]`,
+		`[nil,\`,
+		` 2]`,
+		`:dump`,
+		`:start nope`,
+		`:quit`,
+		`never parsed`,
+	)
+	t.Logf("%s", out)
+	test.Contains(t, out, "list> - \"1\"\n- \"2\"\n")
+	test.Contains(t, out, "  [1, 2 3]\n        ^\n1:7: expected \",\", \"]\"\n")
+	test.Contains(t, out, "num: unknown directive `<nope>`")
+	test.Contains(t, out, "1:2: expected \"nil\", /\\d+/\ndid you mean \"nil\"?\n")
+	test.Contains(t, out, "| - null\n- \"2\"\n")
+	test.Contains(t, out, "%ws /\\s*/\nlist: \"[\" num(s \",\") \"]\"\nnum: \"nil\"\n    | /\\d+/\n")
+	test.Contains(t, out, `no rule named "nope", rules: list, num`)
+	test.NotContains(t, out, "never")
+
+	// the inputs are kept across sessions
+	out = session(":history", "!1")
+	test.Contains(t, out, "   1  [1, 2]\n   2  [1, 2 3]\n   3  [nul]\n   4  [nil,\\n 2]\n")
+	test.Contains(t, out, "> [1, 2]\n")
+	hist2, err := os.ReadFile(hist)
+	test.NoError(t, err)
+	test.EqualsGo(t, 4, strings.Count(string(hist2), "\n"))
+
+	// an empty history has no inputs
+	test.NoError(t, os.WriteFile(hist, nil, 0600))
+	out = session(":history", "!!")
+	test.NotContains(t, out, "   1  ")
+	test.Contains(t, out, "no input 0 in the history\n")
+}
@@ -0,0 +1,4 @@
+// Package example is list.prd compiled by cmd/prdgen, and is used to check the generated code against the runtime
+package example
+
+//go:generate go run .. -grammar list.prd -pkg example -o parser.go
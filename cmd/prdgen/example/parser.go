@@ -0,0 +1,487 @@
+// Code generated by prdgen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// the functions called when a production matches, nil ones return the items as they are
+type Actions struct {
+}
+
+type parser struct {
+	actions *Actions
+	file    string
+	in      []byte
+	at      int
+	commit  bool // true if the current production is committed, used for errors
+}
+
+// returned when the input can't be parsed
+type Error struct {
+	Err    error
+	At     int // offset in the input
+	commit bool
+}
+
+func (this *Error) Error() string { return fmt.Sprintf("%v at %d", this.Err, this.At) }
+func (this *Error) Unwrap() error { return this.Err }
+
+// a parser, the actions are called when a production matches
+type Parser struct {
+	Actions Actions
+}
+
+func (this *Parser) parse(fileName string, in []byte, alt func(p *parser) (any, *Error)) (any, error) {
+	p := &parser{actions: &this.Actions, file: fileName, in: in}
+	p.init()
+	out, err := alt(p)
+	if err != nil {
+		return out, err
+	}
+	p.skip(endRE)
+	if p.at < len(p.in) {
+		return out, p.errorf("unparsed: %q", p.rem(80))
+	}
+	return out, nil
+}
+
+func (p *parser) errorf(f string, args ...any) *Error {
+	return &Error{Err: fmt.Errorf(f, args...), At: p.at, commit: p.commit}
+}
+
+func (p *parser) rem(max int) string {
+	rem := p.in[p.at:]
+	if len(rem) > max {
+		rem = rem[0:max]
+	}
+	return string(rem)
+}
+
+// an action failed, if the production is committed the error is too
+func (p *parser) fail(err *Error, act string) *Error {
+	if err.commit {
+		return err
+	}
+	if p.commit {
+		err = p.errorf("expected %s got %q", act, p.rem(10))
+		err.commit = true
+	}
+	return err
+}
+
+// a single production
+func (p *parser) one(prod func() (any, *Error)) (any, *Error) {
+	commit := p.commit
+	p.commit = false
+	out, err := prod()
+	p.commit = commit
+	return out, err
+}
+
+// try each production in order, the first that succeed is returned, otherwise the farthest error
+func (p *parser) alts(prods ...func() (any, *Error)) (any, *Error) {
+	at, commit := p.at, p.commit
+	var best *Error
+	for _, prod := range prods {
+		p.at, p.commit = at, false
+		out, err := prod()
+		if err == nil || err.commit {
+			p.commit = commit
+			return out, err
+		}
+		if best == nil || err.At > best.At {
+			best = err
+		}
+	}
+	p.at, p.commit = at, commit
+	return nil, best
+}
+
+func (p *parser) skip(ws *regexp.Regexp) *Error {
+	if ws == nil {
+		return nil
+	}
+	m := ws.Find(p.in[p.at:])
+	if m == nil {
+		return p.errorf("can't consume whitespace: ❌ expected /%v/", ws)
+	}
+	p.at += len(m)
+	return nil
+}
+
+func (p *parser) re(ws, re *regexp.Regexp, negative bool) (any, *Error) {
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	m := re.FindIndex(p.in[p.at:])
+	if m == nil {
+		if negative {
+			return "", nil
+		}
+		return "", p.errorf("expected /%v/ got %q", re, p.rem(80))
+	}
+	if negative {
+		return "", p.errorf("unwanted /%v/", re)
+	}
+	out := string(p.in[p.at : p.at+m[1]])
+	p.at += m[1]
+	return out, nil
+}
+
+func (p *parser) lit(ws *regexp.Regexp, lit, re string, negative bool) (any, *Error) {
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	if len(p.in)-p.at < len(lit) || string(p.in[p.at:p.at+len(lit)]) != lit {
+		if negative {
+			return "", nil
+		}
+		return "", p.errorf("expected /%v/ got %q", re, p.rem(80))
+	}
+	if negative {
+		return "", p.errorf("unwanted /%v/", re)
+	}
+	p.at += len(lit)
+	return lit, nil
+}
+
+func (p *parser) check(ws *regexp.Regexp, cond func() bool, negative bool, what string) (any, *Error) {
+	at := p.at
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	if cond() == negative {
+		p.at = at
+		return nil, p.errorf("expected %s got %q", what, p.rem(80))
+	}
+	if negative {
+		p.at = at
+	}
+	return nil, nil
+}
+
+func (p *parser) not(alt func(p *parser) (any, *Error), name string) (any, *Error) {
+	at, commit := p.at, p.commit
+	_, err := alt(p)
+	p.at, p.commit = at, commit
+	if err == nil {
+		return nil, p.errorf("unwanted %s", name)
+	}
+	return nil, nil
+}
+
+func (p *parser) ahead(f func() (any, *Error)) (any, *Error) {
+	at, commit := p.at, p.commit
+	out, err := f()
+	p.at, p.commit = at, commit
+	return out, err
+}
+
+func (p *parser) eof() bool { return p.at == len(p.in) }
+func (p *parser) bol() bool { return p.at == 0 || p.in[p.at-1] == '\n' }
+
+func (p *parser) eol() bool {
+	rem := p.in[p.at:]
+	return len(rem) == 0 || rem[0] == '\n' || (len(rem) > 1 && rem[0] == '\r' && rem[1] == '\n')
+}
+
+func (p *parser) col() int {
+	i := p.at
+	for i > 0 && p.in[i-1] != '\n' {
+		i--
+	}
+	return p.at - i + 1
+}
+
+func list(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case []any:
+		return v == nil
+	}
+	return false
+}
+
+func (p *parser) init() {}
+
+var endRE = re7
+
+// parse the whole input, starting from `doc`
+func (this *Parser) ParseDoc(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_doc)
+}
+
+// parse the whole input, starting from `entry`
+func (this *Parser) ParseEntry(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_entry)
+}
+
+// parse the whole input, starting from `key`
+func (this *Parser) ParseKey(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_key)
+}
+
+// parse the whole input, starting from `value`
+func (this *Parser) ParseValue(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_value)
+}
+
+// doc: `entry(s) <eof>` (list.prd:5)
+func (p *parser) prod_doc_0() (any, *Error) {
+	var err *Error
+	v0, err := p.alt_doc__rep1()
+	if err != nil {
+		return nil, p.fail(err, "doc,rep1")
+	}
+	_, err = p.check(re0, p.eof, false, "~<eof>")
+	if err != nil {
+		return nil, p.fail(err, "~<eof>")
+	}
+	return v0, nil
+}
+
+func (p *parser) alt_doc() (any, *Error) {
+	return p.one(p.prod_doc_0)
+}
+
+// doc,rep1: `entry doc,rep1_` (list.prd:5)
+func (p *parser) prod_doc__rep1_0() (any, *Error) {
+	var err *Error
+	v0, err := p.alt_entry()
+	if err != nil {
+		return nil, p.fail(err, "entry")
+	}
+	v1, err := p.alt_doc__rep1_()
+	if err != nil {
+		return nil, p.fail(err, "doc,rep1_")
+	}
+	return append([]any{v0}, list(v1)...), nil
+}
+
+func (p *parser) alt_doc__rep1() (any, *Error) {
+	return p.one(p.prod_doc__rep1_0)
+}
+
+// doc,rep1_: `entry doc,rep1_` (list.prd:5)
+func (p *parser) prod_doc__rep1__0() (any, *Error) {
+	var err *Error
+	v0, err := p.alt_entry()
+	if err != nil {
+		return nil, p.fail(err, "entry")
+	}
+	v1, err := p.alt_doc__rep1_()
+	if err != nil {
+		return nil, p.fail(err, "doc,rep1_")
+	}
+	return append([]any{v0}, list(v1)...), nil
+}
+
+// doc,rep1_: <empty> (list.prd:5)
+func (p *parser) prod_doc__rep1__1() (any, *Error) {
+	return []any{}, nil
+}
+
+func (p *parser) alt_doc__rep1_() (any, *Error) {
+	return p.alts(p.prod_doc__rep1__0, p.prod_doc__rep1__1)
+}
+
+// entry: `key + "=" value ~/;?/ <eol> ~/\n*/` (list.prd:6)
+func (p *parser) prod_entry_0() (any, *Error) {
+	var err *Error
+	v0, err := p.alt_key()
+	if err != nil {
+		return nil, p.fail(err, "key")
+	}
+	p.commit = true
+	_, err = p.lit(re0, "=", "^=", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^=/")
+	}
+	v1, err := p.alt_value()
+	if err != nil {
+		return nil, p.fail(err, "value")
+	}
+	_, err = p.re(re0, re1, false)
+	if err != nil {
+		return nil, p.fail(err, "~/^;?/")
+	}
+	_, err = p.check(re0, p.eol, false, "~<eol>")
+	if err != nil {
+		return nil, p.fail(err, "~<eol>")
+	}
+	_, err = p.re(re0, re2, false)
+	if err != nil {
+		return nil, p.fail(err, "~/^\\n*/")
+	}
+	return []any{v0, v1}, nil
+}
+
+func (p *parser) alt_entry() (any, *Error) {
+	return p.one(p.prod_entry_0)
+}
+
+// key: `!"end" /[a-z]+/` (list.prd:7)
+func (p *parser) prod_key_0() (any, *Error) {
+	var err *Error
+	_, err = p.lit(re0, "end", "^end", true)
+	if err != nil {
+		return nil, p.fail(err, "~!/^end/")
+	}
+	v0, err := p.re(re0, re3, false)
+	if err != nil {
+		return nil, p.fail(err, "/^[a-z]+/")
+	}
+	return v0, nil
+}
+
+func (p *parser) alt_key() (any, *Error) {
+	return p.one(p.prod_key_0)
+}
+
+// value: `"[" value(s ",") "]"` (list.prd:8)
+func (p *parser) prod_value_0() (any, *Error) {
+	var err *Error
+	_, err = p.lit(re0, "[", "^\\[", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^\\[/")
+	}
+	v0, err := p.alt_value__rep2()
+	if err != nil {
+		return nil, p.fail(err, "value,rep2")
+	}
+	_, err = p.lit(re0, "]", "^\\]", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^\\]/")
+	}
+	return v0, nil
+}
+
+// value: `&/\d/ /\d+/ [ "." /\d+/ ]` (list.prd:9)
+func (p *parser) prod_value_1() (any, *Error) {
+	var err *Error
+	v0, err := p.ahead(func() (any, *Error) { return p.re(re0, re4, false) })
+	if err != nil {
+		return nil, p.fail(err, "&/^\\d/")
+	}
+	v1, err := p.re(re0, re5, false)
+	if err != nil {
+		return nil, p.fail(err, "/^\\d+/")
+	}
+	v2, err := p.alt_value__opt3()
+	if err != nil {
+		return nil, p.fail(err, "value,opt3")
+	}
+	return []any{v0, v1, v2}, nil
+}
+
+// value: `/"[^"]*"/` (list.prd:10)
+func (p *parser) prod_value_2() (any, *Error) {
+	var err *Error
+	v0, err := p.re(re0, re6, false)
+	if err != nil {
+		return nil, p.fail(err, "/^\"[^\"]*\"/")
+	}
+	return v0, nil
+}
+
+// value: `"end"` (list.prd:11)
+func (p *parser) prod_value_3() (any, *Error) {
+	var err *Error
+	_, err = p.lit(re0, "end", "^end", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^end/")
+	}
+	return nil, nil
+}
+
+func (p *parser) alt_value() (any, *Error) {
+	return p.alts(p.prod_value_0, p.prod_value_1, p.prod_value_2, p.prod_value_3)
+}
+
+// value,opt3: `"." /\d+/` (list.prd:9)
+func (p *parser) prod_value__opt3_0() (any, *Error) {
+	var err *Error
+	_, err = p.lit(re0, ".", "^\\.", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^\\./")
+	}
+	v0, err := p.re(re0, re5, false)
+	if err != nil {
+		return nil, p.fail(err, "/^\\d+/")
+	}
+	return v0, nil
+}
+
+// value,opt3: <empty> (list.prd:9)
+func (p *parser) prod_value__opt3_1() (any, *Error) {
+	return nil, nil
+}
+
+func (p *parser) alt_value__opt3() (any, *Error) {
+	return p.alts(p.prod_value__opt3_0, p.prod_value__opt3_1)
+}
+
+// value,rep2: `value value,rep2_` (list.prd:8)
+func (p *parser) prod_value__rep2_0() (any, *Error) {
+	var err *Error
+	v0, err := p.alt_value()
+	if err != nil {
+		return nil, p.fail(err, "value")
+	}
+	v1, err := p.alt_value__rep2_()
+	if err != nil {
+		return nil, p.fail(err, "value,rep2_")
+	}
+	return append([]any{v0}, list(v1)...), nil
+}
+
+func (p *parser) alt_value__rep2() (any, *Error) {
+	return p.one(p.prod_value__rep2_0)
+}
+
+// value,rep2_: `value value,rep2_` (list.prd:8)
+func (p *parser) prod_value__rep2__0() (any, *Error) {
+	var err *Error
+	_, err = p.lit(re0, ",", "^,", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^,/")
+	}
+	v0, err := p.alt_value()
+	if err != nil {
+		return nil, p.fail(err, "value")
+	}
+	v1, err := p.alt_value__rep2_()
+	if err != nil {
+		return nil, p.fail(err, "value,rep2_")
+	}
+	return append([]any{v0}, list(v1)...), nil
+}
+
+// value,rep2_: <empty> (list.prd:8)
+func (p *parser) prod_value__rep2__1() (any, *Error) {
+	return []any{}, nil
+}
+
+func (p *parser) alt_value__rep2_() (any, *Error) {
+	return p.alts(p.prod_value__rep2__0, p.prod_value__rep2__1)
+}
+
+var (
+	re0 = regexp.MustCompile(`^[ \t]*`)
+	re1 = regexp.MustCompile(`^;?`)
+	re2 = regexp.MustCompile(`^\n*`)
+	re3 = regexp.MustCompile(`^[a-z]+`)
+	re4 = regexp.MustCompile(`^\d`)
+	re5 = regexp.MustCompile(`^\d+`)
+	re6 = regexp.MustCompile(`^"[^"]*"`)
+	re7 = regexp.MustCompile(`^\s*`)
+)
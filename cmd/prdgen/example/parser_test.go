@@ -0,0 +1,45 @@
+package example
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func TestExample(t *testing.T) {
+	text, err := os.ReadFile("list.prd")
+	test.NoError(t, err)
+	g, err := parse.LoadGrammar("list.prd", text)
+	test.NoError(t, err)
+	test.NoError(t, g.Verify())
+	var p Parser
+
+	for _, in := range []string{
+		"a = 1\n",
+		"a = 1.5; \nb=[1, \"x\", [2]]\n\n",
+		"a = end\nb = \"end\"",
+		"a = [1 2]\n",   // missing separator
+		"a 1\n",         // missing `=` after the commit
+		"end = 1\n",     // reserved
+		"a = 1 b = 2\n", // not at the end of the line
+		"a = x\n",
+		"",
+	} {
+		exp, _, expErr := g.Parse("doc", []byte(in))
+		got, gotErr := p.ParseDoc("", []byte(in))
+		if expErr != nil {
+			t.Logf("%q: %v", in, expErr)
+			test.Error(t, gotErr)
+			test.EqualsGo(t, expErr.Error(), gotErr.Error())
+			continue
+		}
+		test.NoError(t, gotErr)
+		expJSON, _ := json.Marshal(exp)
+		gotJSON, _ := json.Marshal(got)
+		t.Logf("%q: %s", in, gotJSON)
+		test.EqualsGo(t, string(expJSON), string(gotJSON))
+	}
+}
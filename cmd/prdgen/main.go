@@ -0,0 +1,82 @@
+// Command prdgen compiles a grammar into a standalone Go parser, without reflection.
+//
+//	prdgen -grammar calc.prd -pkg calc -o calc/parser.go
+//	prdgen -registered default -pkg compiled -o parser.go
+//
+// Grammars built in Go must be registered with parse.Register() by a package imported here.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ohait/parse-rec-descent-go"
+	_ "github.com/ohait/parse-rec-descent-go/default_grammar"
+)
+
+func main() {
+	grammarFile := flag.String("grammar", "", "textual grammar (.prd) to compile")
+	registered := flag.String("registered", "", "registered grammar to compile: "+strings.Join(parse.RegisteredNames(), ", "))
+	pkg := flag.String("pkg", "parser", "name of the generated package")
+	pkgPath := flag.String("pkgpath", "", "import path of the generated package")
+	start := flag.String("start", "", "comma separated rules to generate a Parse function for (default: all the public rules)")
+	out := flag.String("o", "", "output file (default: stdout)")
+	flag.Parse()
+
+	if err := run(*grammarFile, *registered, *out, parse.GoOptions{
+		Package: *pkg,
+		PkgPath: *pkgPath,
+		Start:   split(*start),
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "prdgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(grammarFile, registered, out string, opts parse.GoOptions) error {
+	var g *parse.Grammar
+	switch {
+	case grammarFile != "" && registered != "":
+		return fmt.Errorf("use either -grammar or -registered")
+	case grammarFile != "":
+		text, err := os.ReadFile(grammarFile)
+		if err != nil {
+			return err
+		}
+		g, err = parse.LoadGrammar(grammarFile, text)
+		if err != nil {
+			return err
+		}
+	case registered != "":
+		g = parse.Registered(registered)
+		if g == nil {
+			return fmt.Errorf("no grammar registered as %q", registered)
+		}
+	default:
+		return fmt.Errorf("missing -grammar or -registered")
+	}
+	if err := g.Verify(); err != nil {
+		return err
+	}
+	src, err := g.GoSource(opts)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0644)
+}
+
+func split(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
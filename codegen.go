@@ -0,0 +1,738 @@
+package parse
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// options for GoSource()
+type GoOptions struct {
+	// name of the generated package (default "parser")
+	Package string
+
+	// import path of the generated package, so its own types are not qualified
+	PkgPath string
+
+	// rules with a Parse function (default: all the public rules)
+	Start []string
+
+	// command shown in the header (default "prdgen")
+	Command string
+}
+
+// generate the source of a standalone Go parser with the same semantics, without reflection
+// each production with a Return() becomes a typed field of the generated `Actions` struct, which are called
+// directly (nil ones behave like productions without Return)
+// lexers, indentation directives and custom error messages are not supported
+func (this *Grammar) GoSource(opts GoOptions) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "parser"
+	}
+	if opts.Command == "" {
+		opts.Command = "prdgen"
+	}
+	if this.Lexer != nil {
+		return nil, ctx.NewErrorf(nil, "grammars with a Lexer are not supported by the code generator")
+	}
+	if len(opts.Start) == 0 {
+		opts.Start = this.ruleNames()
+	}
+	gen := &goGen{
+		g:       this,
+		opts:    opts,
+		imports: map[string]string{},
+		res:     map[string]string{},
+		convs:   map[reflect.Type]string{},
+	}
+
+	var names []string
+	for name, alt := range this.alts {
+		if len(alt.prods) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := gen.alt(this.alts[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	var parsers strings.Builder
+	for _, name := range opts.Start {
+		alt := this.alts[name]
+		if alt == nil || len(alt.prods) == 0 {
+			return nil, ctx.NewErrorf(nil, "no prod named %q", name)
+		}
+		fmt.Fprintf(&parsers, "\n// parse the whole input, starting from `%s`\n", name)
+		fmt.Fprintf(&parsers, "func (this *Parser) Parse%s(fileName string, in []byte) (any, error) {\n", goExported(name))
+		fmt.Fprintf(&parsers, "\treturn this.parse(fileName, in, (*parser).alt_%s)\n}\n", goIdent(name))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by %s. DO NOT EDIT.\n\n", opts.Command)
+	fmt.Fprintf(&b, "package %s\n\n", opts.Package)
+	b.WriteString("import (\n\t\"fmt\"\n\t\"regexp\"\n")
+	var paths []string
+	for path := range gen.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		b.WriteString("\n")
+	}
+	for _, path := range paths {
+		if alias := gen.imports[path]; alias != path[strings.LastIndex(path, "/")+1:] {
+			fmt.Fprintf(&b, "\t%s %q\n", alias, path)
+		} else {
+			fmt.Fprintf(&b, "\t%q\n", path)
+		}
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// the functions called when a production matches, nil ones return the items as they are\n")
+	b.WriteString("type Actions struct {\n")
+	b.WriteString(gen.fields.String())
+	b.WriteString("}\n")
+
+	b.WriteString("\ntype parser struct {\n\tactions *Actions\n\tfile    string\n\tin      []byte\n\tat      int\n")
+	b.WriteString("\tcommit  bool // true if the current production is committed, used for errors\n")
+	if gen.usePos {
+		b.WriteString("\tsrc     *parse.Src\n")
+	}
+	b.WriteString("}\n")
+	b.WriteString(goRuntime)
+	if gen.usePos {
+		b.WriteString(goRuntimePos)
+	} else {
+		b.WriteString(goRuntimeNoPos)
+	}
+	if this.End != nil {
+		fmt.Fprintf(&b, "\nvar endRE = %s\n", gen.re(this.End))
+	} else {
+		b.WriteString("\nvar endRE *regexp.Regexp\n")
+	}
+	b.WriteString(parsers.String())
+	b.WriteString(gen.body.String())
+	if len(gen.reList) > 0 {
+		b.WriteString("\nvar (\n")
+		for _, decl := range gen.reList {
+			b.WriteString(decl)
+		}
+		b.WriteString(")\n")
+	}
+	b.WriteString(gen.convCode.String())
+
+	out, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return []byte(b.String()), ctx.NewErrorf(nil, "can't format the generated code: %v", err)
+	}
+	return out, nil
+}
+
+type goGen struct {
+	g    *Grammar
+	opts GoOptions
+
+	imports map[string]string // path => alias
+
+	res    map[string]string // regexp => var name
+	reList []string          // declarations
+
+	convs    map[reflect.Type]string // type => conversion function
+	convCode strings.Builder
+	sources  []reflect.Type // the types the values can have, see conv()
+
+	usePos bool // some actions expect a Pos
+
+	fields strings.Builder
+	body   strings.Builder
+}
+
+func (this *goGen) alt(alt *Alts) error {
+	w := func(f string, args ...any) { fmt.Fprintf(&this.body, f, args...) }
+	if alt.label != "" {
+		return ctx.NewErrorf(nil, "%s: the label %q is not supported by the code generator", alt.Name, alt.label)
+	}
+	var prods []string
+	for i, p := range alt.prods {
+		prods = append(prods, fmt.Sprintf("p.prod_%s_%d", goIdent(alt.Name), i))
+		if err := this.prod(alt, i, p); err != nil {
+			return err
+		}
+	}
+	w("\nfunc (p *parser) alt_%s() (any, *Error) {\n", goIdent(alt.Name))
+	if len(prods) == 1 {
+		w("\treturn p.one(%s)\n}\n", prods[0])
+	} else {
+		w("\treturn p.alts(%s)\n}\n", strings.Join(prods, ", "))
+	}
+	return nil
+}
+
+func (this *goGen) prod(alt *Alts, i int, p *Prod) error {
+	w := func(f string, args ...any) { fmt.Fprintf(&this.body, f, args...) }
+	d := strings.Join(strings.Fields(p.Directive), " ")
+	if d == "" {
+		d = "<empty>"
+	} else {
+		d = "`" + d + "`"
+	}
+	comment := fmt.Sprintf("%s: %s (%s)", alt.Name, d, p.src)
+	if p.expect != "" {
+		return ctx.NewErrorf(nil, "%s: Expect(%q) is not supported by the code generator", p.src, p.expect)
+	}
+
+	field := ""       // the action to call, if any
+	var args []string // and its arguments
+	wantPos := false
+	if alt.internal == "" && p.retSig != nil {
+		t := p.retSig
+		field = fmt.Sprintf("%s_%d", goExported(alt.Name), i)
+		var ins, outs []string
+		for j := 0; j < t.NumIn(); j++ {
+			s, err := this.typeName(t.In(j))
+			if err != nil {
+				return ctx.NewErrorf(nil, "%s: %v", p.src, err)
+			}
+			ins = append(ins, s)
+		}
+		for j := 0; j < t.NumOut(); j++ {
+			s, err := this.typeName(t.Out(j))
+			if err != nil {
+				return ctx.NewErrorf(nil, "%s: %v", p.src, err)
+			}
+			outs = append(outs, s)
+		}
+		sig := "func(" + strings.Join(ins, ", ") + ")"
+		switch len(outs) {
+		case 1:
+			sig += " " + outs[0]
+		default:
+			sig += " (" + strings.Join(outs, ", ") + ")"
+		}
+		fmt.Fprintf(&this.fields, "\t// %s\n\t%s %s\n", comment, field, sig)
+		j := 0
+		if t.NumIn() > 0 && t.In(0) == reflect.TypeOf(Pos{}) {
+			this.usePos = true
+			wantPos = true
+			args = append(args, "p.pos(from)")
+			j = 1
+		}
+		for k := 0; j < t.NumIn(); j, k = j+1, k+1 {
+			conv, err := this.conv(t.In(j))
+			if err != nil {
+				return ctx.NewErrorf(nil, "%s: %v", p.src, err)
+			}
+			if conv == "" {
+				args = append(args, fmt.Sprintf("v%d", k))
+			} else {
+				args = append(args, fmt.Sprintf("%s(v%d)", conv, k))
+			}
+		}
+	}
+
+	w("\n// %s\n", comment)
+	w("func (p *parser) prod_%s_%d() (any, *Error) {\n", goIdent(alt.Name), i)
+	if wantPos {
+		w("\tfrom := p.at\n")
+	}
+	var items []string
+	for _, act := range p.actions {
+		if !act.commit {
+			w("\tvar err *Error\n")
+			break
+		}
+	}
+	for _, act := range p.actions {
+		if act.commit {
+			w("\tp.commit = true\n")
+			continue
+		}
+		expr, err := this.action(p, act)
+		if err != nil {
+			return ctx.NewErrorf(nil, "%s: %v", p.src, err)
+		}
+		if act.silent {
+			w("\t_, err = %s\n", expr)
+		} else {
+			v := fmt.Sprintf("v%d", len(items))
+			items = append(items, v)
+			w("\t%s, err := %s\n", v, expr)
+		}
+		w("\tif err != nil {\n\t\treturn nil, p.fail(err, %q)\n\t}\n", act.String())
+	}
+
+	switch {
+	case alt.internal == "rep" || alt.internal == "rep_":
+		if len(items) == 0 {
+			w("\treturn []any{}, nil\n}\n")
+			return nil
+		}
+		last := items[len(items)-1]
+		w("\treturn append([]any{%s}, list(%s)...), nil\n}\n", strings.Join(items[0:len(items)-1], ", "), last)
+		return nil
+	case field != "":
+		w("\tif f := p.actions.%s; f != nil {\n", field)
+		if p.retErr {
+			w("\t\tout, e := f(%s)\n", strings.Join(args, ", "))
+			w("\t\tif e != nil {\n\t\t\treturn out, &Error{Err: e, At: p.at, commit: p.commit}\n\t\t}\n")
+			w("\t\treturn out, nil\n\t}\n")
+		} else {
+			w("\t\treturn f(%s), nil\n\t}\n", strings.Join(args, ", "))
+		}
+	}
+	switch len(items) {
+	case 0:
+		w("\treturn nil, nil\n}\n")
+	case 1:
+		w("\treturn %s, nil\n}\n", items[0])
+	default:
+		w("\treturn []any{%s}, nil\n}\n", strings.Join(items, ", "))
+	}
+	return nil
+}
+
+// a go expression returning (any, *Error)
+func (this *goGen) action(p *Prod, act action) (string, error) {
+	if act.ahead {
+		inner := act
+		inner.ahead = false
+		expr, err := this.action(p, inner)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("p.ahead(func() (any, *Error) { return %s })", expr), nil
+	}
+	ws := this.re(p.ws())
+	switch {
+	case act.check != nil:
+		m := regexp.MustCompile(`^<(\w+)(?::([^>]*))?>$`).FindStringSubmatch(act.builtin)
+		var cond string
+		switch m[1] {
+		case "eof", "bol", "eol":
+			cond = "p." + m[1]
+		case "col":
+			col, _ := strconv.Atoi(m[2])
+			cond = fmt.Sprintf("func() bool { return p.col() == %d }", col)
+		default:
+			return "", ctx.NewErrorf(nil, "directive %s is not supported by the code generator", act.builtin)
+		}
+		return fmt.Sprintf("p.check(%s, %s, %v, %q)", ws, cond, act.negative, act.String()), nil
+	case act.lit != "":
+		return fmt.Sprintf("p.lit(%s, %q, %q, %v)", ws, act.lit, act.re.String(), act.negative), nil
+	case act.re != nil:
+		return fmt.Sprintf("p.re(%s, %s, %v)", ws, this.re(act.re), act.negative), nil
+	case act.prod != "":
+		alt := this.g.alts[act.prod]
+		if alt == nil || len(alt.prods) == 0 {
+			return "", ctx.NewErrorf(nil, "refers to empty %q", act.prod)
+		}
+		if act.negative {
+			return fmt.Sprintf("p.not((*parser).alt_%s, %q)", goIdent(act.prod), act.prod), nil
+		}
+		return fmt.Sprintf("p.alt_%s()", goIdent(act.prod)), nil
+	}
+	return "", ctx.NewErrorf(nil, "empty action")
+}
+
+// the name of a package variable with the compiled regexp, or "nil"
+func (this *goGen) re(re *regexp.Regexp) string {
+	if re == nil {
+		return "nil"
+	}
+	s := re.String()
+	if name, ok := this.res[s]; ok {
+		return name
+	}
+	name := fmt.Sprintf("re%d", len(this.res))
+	this.res[s] = name
+	lit := strconv.Quote(s)
+	if !strings.Contains(s, "`") && strconv.CanBackquote(s) {
+		lit = "`" + s + "`"
+	}
+	this.reList = append(this.reList, fmt.Sprintf("\t%s = regexp.MustCompile(%s)\n", name, lit))
+	return name
+}
+
+// the name of a function converting `any` to the given type, like coerce() does: the values of the types the
+// actions return (or strings) are converted if Go can convert them, and slices element by element
+// returns "" for `any`, which needs no conversion
+func (this *goGen) conv(t reflect.Type) (string, error) {
+	if t.Kind() == reflect.Interface && t.Name() == "" && t.NumMethod() == 0 {
+		return "", nil
+	}
+	if name, ok := this.convs[t]; ok {
+		return name, nil
+	}
+	name := fmt.Sprintf("conv%d", len(this.convs))
+	this.convs[t] = name
+	tn, err := this.typeName(t)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nfunc %s(v any) %s {\n", name, tn)
+	fmt.Fprintf(&b, "\tif t, ok := v.(%s); ok || v == nil {\n\t\treturn t\n\t}\n", tn)
+	var elem string
+	if t.Kind() == reflect.Slice {
+		elem, err = this.conv(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\tif l, ok := v.([]any); ok && l != nil {\n\t\tout := make(%s, len(l))\n", tn)
+		fmt.Fprintf(&b, "\t\tfor i, x := range l {\n\t\t\tout[i] = %s(x)\n\t\t}\n\t\treturn out\n\t}\n", elem)
+	}
+	var cases strings.Builder
+	for _, s := range this.valueTypes() {
+		if s == t || t.Kind() == reflect.Interface {
+			continue
+		}
+		slices := s.Kind() == reflect.Slice && t.Kind() == reflect.Slice && s.Elem().ConvertibleTo(t.Elem())
+		if !slices && !s.ConvertibleTo(t) {
+			continue
+		}
+		sn, err := this.typeName(s)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case slices:
+			fmt.Fprintf(&cases, "\tcase %s:\n\t\tout := make(%s, len(x))\n", sn, tn)
+			fmt.Fprintf(&cases, "\t\tfor i, e := range x {\n\t\t\tout[i] = %s(e)\n\t\t}\n\t\treturn out\n", elem)
+		case t.Kind() == reflect.String && s.Kind() >= reflect.Int && s.Kind() <= reflect.Uintptr:
+			fmt.Fprintf(&cases, "\tcase %s:\n\t\treturn %s(rune(x))\n", sn, tn) // like reflect does, go vet wants it explicit
+		default:
+			fmt.Fprintf(&cases, "\tcase %s:\n\t\treturn %s(x)\n", sn, tn)
+		}
+	}
+	if cases.Len() > 0 {
+		fmt.Fprintf(&b, "\tswitch x := v.(type) {\n%s\t}\n", cases.String())
+	}
+	if t.Kind() != reflect.Interface {
+		fmt.Fprintf(&b, "\tif isZero(v) {\n\t\tvar zero %s\n\t\treturn zero\n\t}\n", tn)
+	}
+	fmt.Fprintf(&b, "\tpanic(fmt.Sprintf(\"can't convert %%T to %s\", v))\n}\n", tn)
+	this.convCode.WriteString(b.String())
+	return name, nil
+}
+
+// the concrete types the values passed to the actions can have: strings, and what the actions return
+// (and their elements, for slices), sorted by name
+func (this *goGen) valueTypes() []reflect.Type {
+	if this.sources != nil {
+		return this.sources
+	}
+	seen := map[reflect.Type]bool{}
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		if t.Kind() == reflect.Interface || seen[t] || t == reflect.TypeOf([]any{}) {
+			return // handled by the type assertions
+		}
+		seen[t] = true
+		this.sources = append(this.sources, t)
+		if t.Kind() == reflect.Slice {
+			add(t.Elem())
+		}
+	}
+	add(reflect.TypeOf(""))
+	for _, alt := range this.g.alts {
+		for _, p := range alt.prods {
+			if p.retSig != nil && p.retSig.NumOut() > 0 {
+				add(p.retSig.Out(0))
+			}
+		}
+	}
+	sort.Slice(this.sources, func(i, j int) bool {
+		return this.sources[i].String() < this.sources[j].String()
+	})
+	return this.sources
+}
+
+// the type as written in the generated code, adding the imports as needed
+func (this *goGen) typeName(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if strings.Contains(t.Name(), "[") {
+			return "", ctx.NewErrorf(nil, "generic type %v is not supported by the code generator", t)
+		}
+		if t.PkgPath() == "" || t.PkgPath() == this.opts.PkgPath {
+			return t.Name(), nil
+		}
+		if !token.IsExported(t.Name()) {
+			return "", ctx.NewErrorf(nil, "unexported type %v is not supported by the code generator", t)
+		}
+		return this.importAlias(t.PkgPath()) + "." + t.Name(), nil
+	}
+	elem := func() (string, error) { return this.typeName(t.Elem()) }
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any", nil
+		}
+	case reflect.Slice:
+		e, err := elem()
+		return "[]" + e, err
+	case reflect.Array:
+		e, err := elem()
+		return fmt.Sprintf("[%d]%s", t.Len(), e), err
+	case reflect.Pointer:
+		e, err := elem()
+		return "*" + e, err
+	case reflect.Map:
+		k, err := this.typeName(t.Key())
+		if err != nil {
+			return "", err
+		}
+		e, err := elem()
+		return "map[" + k + "]" + e, err
+	}
+	return "", ctx.NewErrorf(nil, "type %v is not supported by the code generator", t)
+}
+
+func (this *goGen) importAlias(path string) string {
+	if alias, ok := this.imports[path]; ok {
+		return alias
+	}
+	alias := ruleName(path[strings.LastIndex(path, "/")+1:])
+	if path == reflect.TypeOf(Pos{}).PkgPath() {
+		alias = "parse"
+	}
+	used := map[string]bool{"fmt": true, "regexp": true}
+	for _, a := range this.imports {
+		used[a] = true
+	}
+	for i := 2; used[alias]; i++ {
+		alias = fmt.Sprintf("%s%d", strings.TrimRight(alias, "0123456789"), i)
+	}
+	this.imports[path] = alias
+	return alias
+}
+
+// rule names are already valid identifiers, except for the internal ones
+func goIdent(name string) string {
+	return strings.ReplaceAll(name, ",", "__")
+}
+
+func goExported(name string) string {
+	if name[0] == '_' {
+		return "X" + name
+	}
+	return strings.ToUpper(name[0:1]) + name[1:]
+}
+
+// the code shared by all the generated parsers
+const goRuntime = `
+// returned when the input can't be parsed
+type Error struct {
+	Err    error
+	At     int // offset in the input
+	commit bool
+}
+
+func (this *Error) Error() string { return fmt.Sprintf("%v at %d", this.Err, this.At) }
+func (this *Error) Unwrap() error { return this.Err }
+
+// a parser, the actions are called when a production matches
+type Parser struct {
+	Actions Actions
+}
+
+func (this *Parser) parse(fileName string, in []byte, alt func(p *parser) (any, *Error)) (any, error) {
+	p := &parser{actions: &this.Actions, file: fileName, in: in}
+	p.init()
+	out, err := alt(p)
+	if err != nil {
+		return out, err
+	}
+	p.skip(endRE)
+	if p.at < len(p.in) {
+		return out, p.errorf("unparsed: %q", p.rem(80))
+	}
+	return out, nil
+}
+
+func (p *parser) errorf(f string, args ...any) *Error {
+	return &Error{Err: fmt.Errorf(f, args...), At: p.at, commit: p.commit}
+}
+
+func (p *parser) rem(max int) string {
+	rem := p.in[p.at:]
+	if len(rem) > max {
+		rem = rem[0:max]
+	}
+	return string(rem)
+}
+
+// an action failed, if the production is committed the error is too
+func (p *parser) fail(err *Error, act string) *Error {
+	if err.commit {
+		return err
+	}
+	if p.commit {
+		err = p.errorf("expected %s got %q", act, p.rem(10))
+		err.commit = true
+	}
+	return err
+}
+
+// a single production
+func (p *parser) one(prod func() (any, *Error)) (any, *Error) {
+	commit := p.commit
+	p.commit = false
+	out, err := prod()
+	p.commit = commit
+	return out, err
+}
+
+// try each production in order, the first that succeed is returned, otherwise the farthest error
+func (p *parser) alts(prods ...func() (any, *Error)) (any, *Error) {
+	at, commit := p.at, p.commit
+	var best *Error
+	for _, prod := range prods {
+		p.at, p.commit = at, false
+		out, err := prod()
+		if err == nil || err.commit {
+			p.commit = commit
+			return out, err
+		}
+		if best == nil || err.At > best.At {
+			best = err
+		}
+	}
+	p.at, p.commit = at, commit
+	return nil, best
+}
+
+func (p *parser) skip(ws *regexp.Regexp) *Error {
+	if ws == nil {
+		return nil
+	}
+	m := ws.Find(p.in[p.at:])
+	if m == nil {
+		return p.errorf("can't consume whitespace: ❌ expected /%v/", ws)
+	}
+	p.at += len(m)
+	return nil
+}
+
+func (p *parser) re(ws, re *regexp.Regexp, negative bool) (any, *Error) {
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	m := re.FindIndex(p.in[p.at:])
+	if m == nil {
+		if negative {
+			return "", nil
+		}
+		return "", p.errorf("expected /%v/ got %q", re, p.rem(80))
+	}
+	if negative {
+		return "", p.errorf("unwanted /%v/", re)
+	}
+	out := string(p.in[p.at : p.at+m[1]])
+	p.at += m[1]
+	return out, nil
+}
+
+func (p *parser) lit(ws *regexp.Regexp, lit, re string, negative bool) (any, *Error) {
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	if len(p.in)-p.at < len(lit) || string(p.in[p.at:p.at+len(lit)]) != lit {
+		if negative {
+			return "", nil
+		}
+		return "", p.errorf("expected /%v/ got %q", re, p.rem(80))
+	}
+	if negative {
+		return "", p.errorf("unwanted /%v/", re)
+	}
+	p.at += len(lit)
+	return lit, nil
+}
+
+func (p *parser) check(ws *regexp.Regexp, cond func() bool, negative bool, what string) (any, *Error) {
+	at := p.at
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	if cond() == negative {
+		p.at = at
+		return nil, p.errorf("expected %s got %q", what, p.rem(80))
+	}
+	if negative {
+		p.at = at
+	}
+	return nil, nil
+}
+
+func (p *parser) not(alt func(p *parser) (any, *Error), name string) (any, *Error) {
+	at, commit := p.at, p.commit
+	_, err := alt(p)
+	p.at, p.commit = at, commit
+	if err == nil {
+		return nil, p.errorf("unwanted %s", name)
+	}
+	return nil, nil
+}
+
+func (p *parser) ahead(f func() (any, *Error)) (any, *Error) {
+	at, commit := p.at, p.commit
+	out, err := f()
+	p.at, p.commit = at, commit
+	return out, err
+}
+
+func (p *parser) eof() bool { return p.at == len(p.in) }
+func (p *parser) bol() bool { return p.at == 0 || p.in[p.at-1] == '\n' }
+
+func (p *parser) eol() bool {
+	rem := p.in[p.at:]
+	return len(rem) == 0 || rem[0] == '\n' || (len(rem) > 1 && rem[0] == '\r' && rem[1] == '\n')
+}
+
+func (p *parser) col() int {
+	i := p.at
+	for i > 0 && p.in[i-1] != '\n' {
+		i--
+	}
+	return p.at - i + 1
+}
+
+func list(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case []any:
+		return v == nil
+	}
+	return false
+}
+`
+
+const goRuntimePos = `
+func (p *parser) init() {
+	p.src = parse.NewPos(p.file, p.in).Src
+}
+
+func (p *parser) pos(from int) parse.Pos {
+	return parse.Pos{From: from, End: p.at, File: p.file, Src: p.src}
+}
+`
+
+const goRuntimeNoPos = `
+func (p *parser) init() {}
+`
@@ -0,0 +1,75 @@
+package parse
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestGoSource(t *testing.T) {
+	var g Grammar
+	g.Add("list", `"(" item(s ",") ")"`).Return(func(p Pos, items []string) []string { return items })
+	g.Add("item", `!")" /\w+/ [ "?" ]`)
+	src, err := g.GoSource(GoOptions{Package: "list", Start: []string{"list"}})
+	test.NoError(t, err)
+	s := string(src)
+	test.Contains(t, s, "// Code generated by prdgen. DO NOT EDIT.\n\npackage list\n")
+	test.Contains(t, s, "\tparse \"github.com/ohait/parse-rec-descent-go\"\n")
+	test.Contains(t, s, "List_0 func(parse.Pos, []string) []string\n")
+	test.Contains(t, s, "func (this *Parser) ParseList(")
+	test.NotContains(t, s, "ParseItem")
+
+	g.Add("block", `<indent> item`)
+	_, err = g.GoSource(GoOptions{})
+	test.Contains(t, err.Error(), "<indent> is not supported")
+
+	// neither are the custom error messages
+	for _, f := range []func(g *Grammar){
+		func(g *Grammar) { g.Add("list", `"[" item "]"`).Expect("a list") },
+		func(g *Grammar) { g.Alt("item").Label("an item") },
+		func(g *Grammar) { g.Add("item", `<error:expected an item>`) },
+	} {
+		var g Grammar
+		g.Add("list", `"(" item(s ",") ")"`)
+		g.Add("item", `/\w+/`)
+		_, err := g.GoSource(GoOptions{})
+		test.NoError(t, err)
+		f(&g)
+		_, err = g.GoSource(GoOptions{})
+		test.Contains(t, err.Error(), "is not supported by the code generator")
+	}
+}
+
+type genName string
+
+func TestGoSourceConv(t *testing.T) {
+	var g Grammar
+	g.Add("doc", `name num readers raw`).Return(func(n string, f float64, readers []io.Reader, raw []byte) string {
+		return n
+	})
+	g.Add("name", `/[a-z]+/`).Return(func(s string) template.HTML { return template.HTML(s) })
+	g.Add("num", `/\d+/`).Return(func(s string) int { return len(s) })
+	g.Add("readers", `/[a-z]+:/`).Return(func(s string) []*strings.Reader { return []*strings.Reader{strings.NewReader(s)} })
+	g.Add("raw", `/[A-Z]+/`)
+	_, _, err := g.Parse("doc", []byte(`abc12x:XY`))
+	test.NoError(t, err)
+
+	// the generated parser converts like the grammar does
+	src, err := g.GoSource(GoOptions{Package: "conv", Start: []string{"doc"}})
+	test.NoError(t, err)
+	s := string(src)
+	test.Contains(t, s, "\tcase template.HTML:\n\t\treturn string(x)\n")
+	test.Contains(t, s, "\tcase int:\n\t\treturn float64(x)\n")
+	test.Contains(t, s, "\tcase string:\n\t\treturn []uint8(x)\n")
+	test.Contains(t, s, "\tcase []*strings.Reader:\n\t\tout := make([]io.Reader, len(x))\n")
+	test.NotContains(t, s, "\tcase []*strings.Reader:\n\t\tout := make([]uint8, len(x))\n")
+
+	// the unexported types can't be named by the generated parser
+	g.Alt("name").Prods()[0].Return(func(s string) genName { return genName(s) })
+	_, err = g.GoSource(GoOptions{Package: "conv", Start: []string{"doc"}})
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "unexported type parse.genName is not supported")
+}
@@ -0,0 +1,74 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// a terminal which could be typed at the cursor, see CompletionsAt()
+type Completion struct {
+	From     int    // where the completion starts, before the cursor if part of a literal was already typed
+	Text     string // the text of a literal, empty for regexps, builtins and tokens
+	Expected string // the terminal, as in Stats.Expected: `"select"`, `/\d+/`, `<eol>` or a token kind
+	Rule     string // the rule where the terminal is expected, which describes what a regexp is for
+}
+
+// parse the text up to the cursor, and return the terminals which could come next, in the order they were tried
+// literals already partially typed before the cursor are included, starting where they were tried
+// nothing is returned if the text can't be parsed up to the cursor
+func (this *Grammar) CompletionsAt(start string, text []byte, offset int) ([]Completion, error) {
+	if offset < 0 || offset > len(text) {
+		return nil, ctx.NewErrorf(nil, "offset %d out of range [0, %d]", offset, len(text))
+	}
+	alt := this.alts[start]
+	if alt == nil || len(alt.prods) == 0 {
+		return nil, &Error{Kind: UnknownRule, Rule: start, Err: fmt.Errorf("no prod named %q", start)}
+	}
+	c := &completer{seen: map[Completion]bool{}}
+	p := pos{
+		g:        this,
+		src:      &Src{bytes: text[:offset]},
+		stats:    &Stats{},
+		complete: c,
+	}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	_, _ = p.consumeProds(alt.prods...)
+	return c.out, nil
+}
+
+type completer struct {
+	seen map[Completion]bool
+	out  []Completion
+}
+
+// the action failed at the current position, which is at the cursor, or before it if part of a literal was typed
+func (this *completer) add(p *pos, act action) {
+	if act.builtin == "<eof>" {
+		return
+	}
+	cursor := len(p.src.bytes)
+	typed := p.src.bytes[p.at:]
+	c := Completion{
+		From:     p.at,
+		Text:     act.lit,
+		Expected: act.expected(),
+	}
+	if act.p != nil {
+		c.Rule, _, _ = strings.Cut(act.p.Name, ",") // the rule, not the internal `name,rep0`
+	}
+	switch {
+	case p.at == cursor:
+	case act.lit != "" && bytes.HasPrefix([]byte(act.lit), typed):
+	default:
+		return
+	}
+	if !this.seen[c] {
+		this.seen[c] = true
+		this.out = append(this.out, c)
+	}
+}
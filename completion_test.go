@@ -0,0 +1,75 @@
+package parse_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func TestCompletionsAt(t *testing.T) {
+	g, err := parse.LoadGrammar("query.prd", []byte(`%ws /\s*/
+query: "select" cols "from" table [ "where" cond ] <eof>
+cols: col(s ",")
+    | "*"
+col: /[a-z]+/
+table: /[a-z]+/
+cond: col "=" /\d+/
+`))
+	test.NoError(t, err)
+	at := func(text string) []parse.Completion {
+		out, err := g.CompletionsAt("query", []byte(text), len(text))
+		test.NoError(t, err)
+		return out
+	}
+
+	test.EqualsGo(t, []parse.Completion{
+		{From: 0, Text: "select", Expected: `"select"`, Rule: "query"},
+	}, at(""))
+
+	test.EqualsGo(t, []parse.Completion{
+		{From: 0, Text: "select", Expected: `"select"`, Rule: "query"},
+	}, at("sel"))
+
+	test.EqualsGo(t, []parse.Completion{
+		{From: 7, Expected: `/[a-z]+/`, Rule: "col"},
+		{From: 7, Text: "*", Expected: `"*"`, Rule: "cols"},
+	}, at("select "))
+
+	test.EqualsGo(t, []parse.Completion{
+		{From: 9, Text: "from", Expected: `"from"`, Rule: "query"},
+	}, at("select a fr"))
+
+	test.EqualsGo(t, []parse.Completion{
+		{From: 19, Text: "where", Expected: `"where"`, Rule: "query"},
+	}, at("select a, b from t "))
+
+	test.EqualsGo(t, []parse.Completion{
+		{From: 29, Expected: `/\d+/`, Rule: "cond"},
+	}, at("select a, b from t where x = "))
+
+	// nothing can be typed after an error
+	test.EqualsGo(t, 0, len(at("select , ")))
+
+	_, err = g.CompletionsAt("query", []byte("select"), 7)
+	test.Error(t, err)
+	_, err = g.CompletionsAt("nope", []byte("select"), 6)
+	test.Error(t, err)
+}
+
+func TestCompletionsAtCursor(t *testing.T) {
+	var g parse.Grammar
+	ws := regexp.MustCompile(`^\s*`)
+	g.Add("list", `"[" [ item(s ",") ] "]"`).WS = ws
+	g.Add("item", `/\d+/`).WS = ws
+	g.Add("item", `"nil"`).WS = ws
+	test.NoError(t, g.Verify())
+
+	// the text after the cursor is ignored
+	out, err := g.CompletionsAt("list", []byte("[1, n] 3"), 5)
+	test.NoError(t, err)
+	test.EqualsGo(t, []parse.Completion{
+		{From: 4, Text: "nil", Expected: `"nil"`, Rule: "item"},
+	}, out)
+}
@@ -0,0 +1,218 @@
+package parse
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// record which productions, and which of their items, matched across many parses
+// set it as the Grammar.Tracer (or in a MultiTracer), then get a Report() of the grammar:
+//
+//	cov := &parse.Coverage{}
+//	g.Tracer = cov
+//	... parse the corpus ...
+//	fmt.Print(cov.Report(g))
+//	for _, e := range cov.Report(g).Unmatched() { ... }
+type Coverage struct {
+	mu    sync.Mutex
+	prods map[profKey]*covCount
+}
+
+type covCount struct {
+	hits  int
+	items map[int]int
+}
+
+func (this *Coverage) Trace(e Event) {
+	switch e.Kind {
+	case ExitRule:
+		if e.Err != nil || e.Alt < 0 {
+			return
+		}
+		this.mu.Lock()
+		defer this.mu.Unlock()
+		this.count(e.Rule, e.Alt).hits++
+	case MatchItem:
+		this.mu.Lock()
+		defer this.mu.Unlock()
+		this.count(e.Rule, e.Alt).items[e.Item]++
+	}
+}
+
+func (this *Coverage) count(rule string, alt int) *covCount {
+	if this.prods == nil {
+		this.prods = map[profKey]*covCount{}
+	}
+	k := profKey{rule, alt}
+	c := this.prods[k]
+	if c == nil {
+		c = &covCount{items: map[int]int{}}
+		this.prods[k] = c
+	}
+	return c
+}
+
+// forget what was collected so far
+func (this *Coverage) Reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.prods = nil
+}
+
+// the coverage of a production
+type CoverageEntry struct {
+	Rule      string
+	Alt       int
+	Src       string // file:line where the production was added
+	Directive string
+	Hits      int      // how many times the production matched
+	Items     []string // the items of the directive
+	ItemHits  []int    // how many times each item matched, even if the production then failed
+}
+
+// how many items matched at least once
+func (this CoverageEntry) Covered() int {
+	ct := 0
+	for _, h := range this.ItemHits {
+		if h > 0 {
+			ct++
+		}
+	}
+	return ct
+}
+
+// the percentage of the items which matched, an empty production is covered if it matched
+func (this CoverageEntry) Percent() float64 {
+	if len(this.Items) == 0 {
+		if this.Hits > 0 {
+			return 100
+		}
+		return 0
+	}
+	return 100 * float64(this.Covered()) / float64(len(this.Items))
+}
+
+type CoverageReport []CoverageEntry
+
+// the coverage of each production of the grammar, in the same order as Grammar.Dump()
+func (this *Coverage) Report(g *Grammar) CoverageReport {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	names := make([]string, 0, len(g.alts))
+	for name := range g.alts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out CoverageReport
+	for _, name := range names {
+		for i, p := range g.alts[name].prods {
+			e := CoverageEntry{
+				Rule:      name,
+				Alt:       i,
+				Src:       p.src,
+				Directive: p.Directive,
+				ItemHits:  make([]int, len(p.actions)),
+			}
+			c := this.prods[profKey{name, i}]
+			if c != nil {
+				e.Hits = c.hits
+			}
+			for j, act := range p.actions {
+				e.Items = append(e.Items, act.item())
+				if c != nil {
+					e.ItemHits[j] = c.items[j]
+				}
+			}
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// the productions which never matched
+func (this CoverageReport) Unmatched() CoverageReport {
+	var out CoverageReport
+	for _, e := range this {
+		if e.Hits == 0 {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// the percentage of all the items which matched at least once
+func (this CoverageReport) Percent() float64 {
+	items, covered := 0, 0
+	for _, e := range this {
+		if len(e.Items) == 0 {
+			items++
+			if e.Hits > 0 {
+				covered++
+			}
+			continue
+		}
+		items += len(e.Items)
+		covered += e.Covered()
+	}
+	if items == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(items)
+}
+
+// the report in the style of `go tool cover -func`
+func (this CoverageReport) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 8, 1, '\t', 0)
+	for _, e := range this {
+		fmt.Fprintf(w, "%s:\t%s/%d\t`%s`\t%.1f%%\n", e.Src, e.Rule, e.Alt, e.Directive, e.Percent())
+	}
+	fmt.Fprintf(w, "total:\t(items)\t\t%.1f%%\n", this.Percent())
+	w.Flush()
+	return sb.String()
+}
+
+// a standalone HTML page with the grammar as in Grammar.Dump(), each production coloured if it matched,
+// if only some of its items matched, or if nothing did
+func (this CoverageReport) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>grammar coverage</title>
+<style>
+body { background: #fff; font-family: sans-serif; }
+pre { font-size: 14px; }
+.cov0 { color: #c00; }
+.cov1 { color: #b80; }
+.cov2 { color: #080; }
+.hits { color: #888; }
+</style></head><body>
+`)
+	fmt.Fprintf(&b, "<p>%.1f%% of the items matched, <span class=\"cov2\">matched</span>, <span class=\"cov1\">partially matched</span>, <span class=\"cov0\">never matched</span></p>\n<pre>\n", this.Percent())
+	for _, e := range this {
+		class := "cov0"
+		switch {
+		case e.Hits > 0:
+			class = "cov2"
+		case e.Covered() > 0:
+			class = "cov1"
+		}
+		var title []string
+		for i, item := range e.Items {
+			title = append(title, fmt.Sprintf("%s: %d", item, e.ItemHits[i]))
+		}
+		fmt.Fprintf(&b, "<span class=\"%s\" title=\"%s\">%s: %s</span> <span class=\"hits\">%d× %s</span>\n",
+			class,
+			html.EscapeString(strings.Join(title, "\n")),
+			html.EscapeString(e.Rule),
+			html.EscapeString(e.Directive),
+			e.Hits,
+			html.EscapeString(e.Src),
+		)
+	}
+	b.WriteString("</pre>\n</body></html>\n")
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package parse_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func TestCoverage(t *testing.T) {
+	g := tracerGrammar()
+	cov := &parse.Coverage{}
+	g.Tracer = cov
+	for _, in := range []string{"(1,2)", "(3"} {
+		g.Parse("pair", []byte(in))
+	}
+	r := cov.Report(g)
+	t.Logf("\n%s", r)
+	test.EqualsGo(t, strings.Join([]string{
+		"tracer_test.go:20:\tnum/0\t`/\\d+/`\t\t\t100.0%",
+		"tracer_test.go:16:\tpair/0\t`\"(\" + num \",\" num \")\"`\t100.0%",
+		"tracer_test.go:19:\tpair/1\t`num`\t\t\t0.0%",
+		"total:\t\t\t(items)\t\t\t\t87.5%",
+		"",
+	}, "\n"), r.String())
+
+	un := r.Unmatched()
+	test.EqualsGo(t, 1, len(un))
+	test.EqualsGo(t, "pair", un[0].Rule)
+	test.EqualsGo(t, 1, un[0].Alt)
+
+	test.EqualsGo(t, 1, r[1].Hits)
+	test.EqualsGo(t, []string{`"("`, "+", "num", `","`, "num", `")"`}, r[1].Items)
+	test.EqualsGo(t, []int{2, 2, 2, 1, 1, 1}, r[1].ItemHits)
+
+	h := r.HTML()
+	test.Contains(t, h, `<span class="cov0" title="num: 0">pair: num</span>`)
+	test.Contains(t, h, `<span class="cov2" title="&#34;(&#34;: 2`)
+
+	cov.Reset()
+	test.EqualsGo(t, 0.0, cov.Report(g).Percent())
+}
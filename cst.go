@@ -0,0 +1,180 @@
+package parse
+
+import (
+	"strings"
+)
+
+// a node of the concrete syntax tree, see Grammar.ParseCST()
+type Node struct {
+	// name of the production, empty for terminals
+	Rule string
+
+	// the highlight class of the production (see Prod.Class()) or of the terminal (see Prod.TermClass())
+	Class string
+
+	// the matched text, only for terminals
+	Text string
+
+	// span of the node, trivia excluded
+	Pos Pos
+
+	Children []*Node
+
+	// skipped text (whitespaces, comments...) before and after the node
+	// terminals own the trivia, rules share the one of their first and last terminal
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// text skipped while parsing
+type Trivia struct {
+	Text string
+	Pos  Pos
+}
+
+// parse the given text like ParseFile(), but returns a concrete syntax tree
+// actions are still called, but their results are discarded
+// internal productions (repetitions, optional groups) are folded into their parent
+func (this *Grammar) ParseCST(prodName, fileName string, text []byte) (*Node, Stats, error) {
+	var nodes []*Node
+	_, s, err := this.parseFile(prodName, fileName, text, &nodes, nil)
+	if err != nil {
+		return nil, s, err
+	}
+	root := nodes[0]
+	root.fold()
+
+	// assign the trivia in between terminals
+	var leaves []*Node
+	root.walk(func(n *Node) {
+		if n.Rule == "" {
+			leaves = append(leaves, n)
+		}
+	})
+	from := 0
+	trivia := func(from, end int) []Trivia {
+		if from == end {
+			return nil
+		}
+		return []Trivia{{string(text[from:end]), Pos{from, end, fileName, root.Pos.Src}}}
+	}
+	for _, n := range leaves {
+		n.Leading = trivia(from, n.Pos.From)
+		from = n.Pos.End
+	}
+	if len(leaves) == 0 {
+		// nothing was matched, the text goes to the first node without children, shared up to the root
+		n := root
+		for len(n.Children) > 0 {
+			n = n.Children[0]
+		}
+		n.Leading = trivia(0, len(text))
+	} else {
+		leaves[len(leaves)-1].Trailing = trivia(from, len(text))
+	}
+	root.share()
+	return root, s, nil
+}
+
+// returns the original text of the node, trivia included
+// the root node reproduces the whole input
+func Print(n *Node) string {
+	var b strings.Builder
+	n.print(&b)
+	return b.String()
+}
+
+func (this *Node) print(b *strings.Builder) {
+	if len(this.Children) > 0 {
+		for _, c := range this.Children {
+			c.print(b)
+		}
+		return
+	}
+	for _, t := range this.Leading {
+		b.WriteString(t.Text)
+	}
+	b.WriteString(this.Text)
+	for _, t := range this.Trailing {
+		b.WriteString(t.Text)
+	}
+}
+
+func (this *Node) walk(f func(n *Node)) {
+	f(this)
+	for _, c := range this.Children {
+		c.walk(f)
+	}
+}
+
+// replace internal productions with their children
+func (this *Node) fold() {
+	var list []*Node
+	for _, c := range this.Children {
+		c.fold()
+		if strings.Contains(c.Rule, ",") {
+			list = append(list, c.Children...)
+		} else {
+			list = append(list, c)
+		}
+	}
+	this.Children = list
+}
+
+// rules get the trivia and the span from their first and last terminals
+func (this *Node) share() {
+	if len(this.Children) == 0 {
+		return
+	}
+	for _, c := range this.Children {
+		c.share()
+	}
+	first := this.Children[0]
+	last := this.Children[len(this.Children)-1]
+	this.Leading = first.Leading
+	this.Trailing = last.Trailing
+	this.Pos.From = first.Pos.From
+	this.Pos.End = last.Pos.End
+}
+
+// called when entering a production, returns where to store the children
+func (this *pos) cstEnter() *[]*Node {
+	if this.cst == nil {
+		return nil
+	}
+	kids := []*Node{}
+	this.cst = &kids
+	return &kids
+}
+
+// called when a production succeed
+func (this *pos) cstAdd(prod *Prod, kids *[]*Node, end int) {
+	if this.cst == nil {
+		return
+	}
+	*this.cst = append(*this.cst, &Node{
+		Rule:     prod.Name,
+		Class:    prod.class,
+		Pos:      Pos{this.at, end, this.file, this.src},
+		Children: *kids,
+	})
+}
+
+// set the class of the terminal just consumed, see Prod.TermClass()
+func (this *pos) cstClass(class string) {
+	if this.cst == nil || class == "" || len(*this.cst) == 0 {
+		return
+	}
+	(*this.cst)[len(*this.cst)-1].Class = class
+}
+
+// called when a terminal is consumed
+func (this *pos) cstLeaf(from, end int) {
+	if this.cst == nil {
+		return
+	}
+	*this.cst = append(*this.cst, &Node{
+		Text: string(this.src.bytes[from:end]),
+		Pos:  Pos{from, end, this.file, this.src},
+	})
+}
@@ -0,0 +1,78 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestCST(t *testing.T) {
+	g := Grammar{
+		End: CommentsAndWhitespaces,
+	}
+	if testing.Verbose() {
+		g.Log = t.Logf
+	}
+	g.Add("list", `"(" item(s ",") ")"`).WS = CommentsAndWhitespaces
+	g.Add("item", `/\w+/`).WS = CommentsAndWhitespaces
+	g.Add("item", `list`)
+
+	in := "  // list\n( a, b // first\n, (c) )  \n// end\n"
+	root, _, err := g.ParseCST("list", "", []byte(in))
+	test.NoError(t, err)
+	test.EqualsGo(t, in, Print(root))
+
+	test.EqualsGo(t, "list", root.Rule)
+	test.EqualsGo(t, 7, len(root.Children)) // ( a , b , item )
+	test.EqualsGo(t, "  // list\n", root.Leading[0].Text)
+	test.EqualsGo(t, "  \n// end\n", root.Trailing[0].Text)
+
+	b := root.Children[3]
+	test.EqualsGo(t, "item", b.Rule)
+	test.EqualsGo(t, "b", b.Children[0].Text)
+	test.EqualsGo(t, " ", b.Leading[0].Text)
+
+	comma := root.Children[4]
+	test.EqualsGo(t, ",", comma.Text)
+	test.EqualsGo(t, " // first\n", comma.Leading[0].Text)
+	test.EqualsGo(t, 2, comma.Leading[0].Pos.Src.Line(comma.Leading[0].Pos.From))
+
+	inner := root.Children[5]
+	test.EqualsGo(t, "(c)", inner.Pos.Extract(0))
+	test.EqualsGo(t, " (c)", Print(inner))
+}
+
+// nothing matched, all the text is trivia
+func TestCSTEmpty(t *testing.T) {
+	g := Grammar{End: Whitespaces}
+	g.Add("doc", `empty`)
+	g.Add("empty", ``)
+	g.Add("none", ``)
+	for _, start := range []string{"doc", "none"} {
+		root, _, err := g.ParseCST(start, "", []byte("   \n"))
+		test.NoError(t, err)
+		test.EqualsGo(t, "   \n", Print(root))
+		test.EqualsGo(t, "   \n", root.Leading[0].Text)
+	}
+}
+
+func TestCSTLexer(t *testing.T) {
+	g := Grammar{
+		Lexer: sqlLexer(),
+	}
+	g.Add("select", `"select" IDENT(s ",") "from" IDENT`)
+	in := "-- query\nselect a ,b\n  from t -- done"
+	root, _, err := g.ParseCST("select", "", []byte(in))
+	test.NoError(t, err)
+	test.EqualsGo(t, in, Print(root))
+	test.EqualsGo(t, 6, len(root.Children))
+	test.EqualsGo(t, "\n  ", root.Children[4].Leading[0].Text)
+}
+
+func TestCSTIndent(t *testing.T) {
+	g := indentGrammar(t)
+	in := "a:\n  b\n\n  c\nd\n"
+	root, _, err := g.ParseCST("file", "", []byte(in))
+	test.NoError(t, err)
+	test.EqualsGo(t, in, Print(root))
+}
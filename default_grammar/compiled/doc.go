@@ -0,0 +1,4 @@
+// Package compiled is the default grammar compiled by cmd/prdgen, the actions must be set on the Parser
+package compiled
+
+//go:generate go run ../../cmd/prdgen -registered default -pkg compiled -pkgpath github.com/ohait/parse-rec-descent-go/default_grammar/compiled -o parser.go
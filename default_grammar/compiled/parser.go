@@ -0,0 +1,436 @@
+// Code generated by prdgen. DO NOT EDIT.
+
+package compiled
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ohait/parse-rec-descent-go/default_grammar"
+)
+
+// the functions called when a production matches, nil ones return the items as they are
+type Actions struct {
+	// expr: `term expr_` (default_grammar.go:27)
+	Expr_0 func(any, []default_grammar.BinOp) any
+	// expr_: `/[\+\-]/ term expr_` (default_grammar.go:28)
+	Expr__0 func(string, any, []default_grammar.BinOp) []default_grammar.BinOp
+	// factor: `"(" expr ")"` (default_grammar.go:35)
+	Factor_0 func(any) any
+	// term: `factor term_` (default_grammar.go:31)
+	Term_0 func(any, []default_grammar.BinOp) any
+	// term_: `/[\*\/]/ factor term_` (default_grammar.go:32)
+	Term__0 func(string, any, []default_grammar.BinOp) []default_grammar.BinOp
+}
+
+type parser struct {
+	actions *Actions
+	file    string
+	in      []byte
+	at      int
+	commit  bool // true if the current production is committed, used for errors
+}
+
+// returned when the input can't be parsed
+type Error struct {
+	Err    error
+	At     int // offset in the input
+	commit bool
+}
+
+func (this *Error) Error() string { return fmt.Sprintf("%v at %d", this.Err, this.At) }
+func (this *Error) Unwrap() error { return this.Err }
+
+// a parser, the actions are called when a production matches
+type Parser struct {
+	Actions Actions
+}
+
+func (this *Parser) parse(fileName string, in []byte, alt func(p *parser) (any, *Error)) (any, error) {
+	p := &parser{actions: &this.Actions, file: fileName, in: in}
+	p.init()
+	out, err := alt(p)
+	if err != nil {
+		return out, err
+	}
+	p.skip(endRE)
+	if p.at < len(p.in) {
+		return out, p.errorf("unparsed: %q", p.rem(80))
+	}
+	return out, nil
+}
+
+func (p *parser) errorf(f string, args ...any) *Error {
+	return &Error{Err: fmt.Errorf(f, args...), At: p.at, commit: p.commit}
+}
+
+func (p *parser) rem(max int) string {
+	rem := p.in[p.at:]
+	if len(rem) > max {
+		rem = rem[0:max]
+	}
+	return string(rem)
+}
+
+// an action failed, if the production is committed the error is too
+func (p *parser) fail(err *Error, act string) *Error {
+	if err.commit {
+		return err
+	}
+	if p.commit {
+		err = p.errorf("expected %s got %q", act, p.rem(10))
+		err.commit = true
+	}
+	return err
+}
+
+// a single production
+func (p *parser) one(prod func() (any, *Error)) (any, *Error) {
+	commit := p.commit
+	p.commit = false
+	out, err := prod()
+	p.commit = commit
+	return out, err
+}
+
+// try each production in order, the first that succeed is returned, otherwise the farthest error
+func (p *parser) alts(prods ...func() (any, *Error)) (any, *Error) {
+	at, commit := p.at, p.commit
+	var best *Error
+	for _, prod := range prods {
+		p.at, p.commit = at, false
+		out, err := prod()
+		if err == nil || err.commit {
+			p.commit = commit
+			return out, err
+		}
+		if best == nil || err.At > best.At {
+			best = err
+		}
+	}
+	p.at, p.commit = at, commit
+	return nil, best
+}
+
+func (p *parser) skip(ws *regexp.Regexp) *Error {
+	if ws == nil {
+		return nil
+	}
+	m := ws.Find(p.in[p.at:])
+	if m == nil {
+		return p.errorf("can't consume whitespace: ❌ expected /%v/", ws)
+	}
+	p.at += len(m)
+	return nil
+}
+
+func (p *parser) re(ws, re *regexp.Regexp, negative bool) (any, *Error) {
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	m := re.FindIndex(p.in[p.at:])
+	if m == nil {
+		if negative {
+			return "", nil
+		}
+		return "", p.errorf("expected /%v/ got %q", re, p.rem(80))
+	}
+	if negative {
+		return "", p.errorf("unwanted /%v/", re)
+	}
+	out := string(p.in[p.at : p.at+m[1]])
+	p.at += m[1]
+	return out, nil
+}
+
+func (p *parser) lit(ws *regexp.Regexp, lit, re string, negative bool) (any, *Error) {
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	if len(p.in)-p.at < len(lit) || string(p.in[p.at:p.at+len(lit)]) != lit {
+		if negative {
+			return "", nil
+		}
+		return "", p.errorf("expected /%v/ got %q", re, p.rem(80))
+	}
+	if negative {
+		return "", p.errorf("unwanted /%v/", re)
+	}
+	p.at += len(lit)
+	return lit, nil
+}
+
+func (p *parser) check(ws *regexp.Regexp, cond func() bool, negative bool, what string) (any, *Error) {
+	at := p.at
+	if err := p.skip(ws); err != nil {
+		return nil, err
+	}
+	if cond() == negative {
+		p.at = at
+		return nil, p.errorf("expected %s got %q", what, p.rem(80))
+	}
+	if negative {
+		p.at = at
+	}
+	return nil, nil
+}
+
+func (p *parser) not(alt func(p *parser) (any, *Error), name string) (any, *Error) {
+	at, commit := p.at, p.commit
+	_, err := alt(p)
+	p.at, p.commit = at, commit
+	if err == nil {
+		return nil, p.errorf("unwanted %s", name)
+	}
+	return nil, nil
+}
+
+func (p *parser) ahead(f func() (any, *Error)) (any, *Error) {
+	at, commit := p.at, p.commit
+	out, err := f()
+	p.at, p.commit = at, commit
+	return out, err
+}
+
+func (p *parser) eof() bool { return p.at == len(p.in) }
+func (p *parser) bol() bool { return p.at == 0 || p.in[p.at-1] == '\n' }
+
+func (p *parser) eol() bool {
+	rem := p.in[p.at:]
+	return len(rem) == 0 || rem[0] == '\n' || (len(rem) > 1 && rem[0] == '\r' && rem[1] == '\n')
+}
+
+func (p *parser) col() int {
+	i := p.at
+	for i > 0 && p.in[i-1] != '\n' {
+		i--
+	}
+	return p.at - i + 1
+}
+
+func list(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case []any:
+		return v == nil
+	}
+	return false
+}
+
+func (p *parser) init() {}
+
+var endRE *regexp.Regexp
+
+// parse the whole input, starting from `expr`
+func (this *Parser) ParseExpr(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_expr)
+}
+
+// parse the whole input, starting from `expr_`
+func (this *Parser) ParseExpr_(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_expr_)
+}
+
+// parse the whole input, starting from `term`
+func (this *Parser) ParseTerm(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_term)
+}
+
+// parse the whole input, starting from `term_`
+func (this *Parser) ParseTerm_(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_term_)
+}
+
+// parse the whole input, starting from `factor`
+func (this *Parser) ParseFactor(fileName string, in []byte) (any, error) {
+	return this.parse(fileName, in, (*parser).alt_factor)
+}
+
+// expr: `term expr_` (default_grammar.go:27)
+func (p *parser) prod_expr_0() (any, *Error) {
+	var err *Error
+	v0, err := p.alt_term()
+	if err != nil {
+		return nil, p.fail(err, "term")
+	}
+	v1, err := p.alt_expr_()
+	if err != nil {
+		return nil, p.fail(err, "expr_")
+	}
+	if f := p.actions.Expr_0; f != nil {
+		return f(v0, conv0(v1)), nil
+	}
+	return []any{v0, v1}, nil
+}
+
+func (p *parser) alt_expr() (any, *Error) {
+	return p.one(p.prod_expr_0)
+}
+
+// expr_: `/[\+\-]/ term expr_` (default_grammar.go:28)
+func (p *parser) prod_expr__0() (any, *Error) {
+	var err *Error
+	v0, err := p.re(nil, re0, false)
+	if err != nil {
+		return nil, p.fail(err, "/^[\\+\\-]/")
+	}
+	v1, err := p.alt_term()
+	if err != nil {
+		return nil, p.fail(err, "term")
+	}
+	v2, err := p.alt_expr_()
+	if err != nil {
+		return nil, p.fail(err, "expr_")
+	}
+	if f := p.actions.Expr__0; f != nil {
+		return f(conv2(v0), v1, conv0(v2)), nil
+	}
+	return []any{v0, v1, v2}, nil
+}
+
+// expr_: <empty> (default_grammar.go:29)
+func (p *parser) prod_expr__1() (any, *Error) {
+	return nil, nil
+}
+
+func (p *parser) alt_expr_() (any, *Error) {
+	return p.alts(p.prod_expr__0, p.prod_expr__1)
+}
+
+// factor: `"(" expr ")"` (default_grammar.go:35)
+func (p *parser) prod_factor_0() (any, *Error) {
+	var err *Error
+	_, err = p.lit(nil, "(", "^\\(", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^\\(/")
+	}
+	v0, err := p.alt_expr()
+	if err != nil {
+		return nil, p.fail(err, "expr")
+	}
+	_, err = p.lit(nil, ")", "^\\)", false)
+	if err != nil {
+		return nil, p.fail(err, "~/^\\)/")
+	}
+	if f := p.actions.Factor_0; f != nil {
+		return f(v0), nil
+	}
+	return v0, nil
+}
+
+// factor: `/\d+/` (default_grammar.go:36)
+func (p *parser) prod_factor_1() (any, *Error) {
+	var err *Error
+	v0, err := p.re(nil, re1, false)
+	if err != nil {
+		return nil, p.fail(err, "/^\\d+/")
+	}
+	return v0, nil
+}
+
+func (p *parser) alt_factor() (any, *Error) {
+	return p.alts(p.prod_factor_0, p.prod_factor_1)
+}
+
+// term: `factor term_` (default_grammar.go:31)
+func (p *parser) prod_term_0() (any, *Error) {
+	var err *Error
+	v0, err := p.alt_factor()
+	if err != nil {
+		return nil, p.fail(err, "factor")
+	}
+	v1, err := p.alt_term_()
+	if err != nil {
+		return nil, p.fail(err, "term_")
+	}
+	if f := p.actions.Term_0; f != nil {
+		return f(v0, conv0(v1)), nil
+	}
+	return []any{v0, v1}, nil
+}
+
+func (p *parser) alt_term() (any, *Error) {
+	return p.one(p.prod_term_0)
+}
+
+// term_: `/[\*\/]/ factor term_` (default_grammar.go:32)
+func (p *parser) prod_term__0() (any, *Error) {
+	var err *Error
+	v0, err := p.re(nil, re2, false)
+	if err != nil {
+		return nil, p.fail(err, "/^[\\*\\/]/")
+	}
+	v1, err := p.alt_factor()
+	if err != nil {
+		return nil, p.fail(err, "factor")
+	}
+	v2, err := p.alt_term_()
+	if err != nil {
+		return nil, p.fail(err, "term_")
+	}
+	if f := p.actions.Term__0; f != nil {
+		return f(conv2(v0), v1, conv0(v2)), nil
+	}
+	return []any{v0, v1, v2}, nil
+}
+
+// term_: <empty> (default_grammar.go:33)
+func (p *parser) prod_term__1() (any, *Error) {
+	return nil, nil
+}
+
+func (p *parser) alt_term_() (any, *Error) {
+	return p.alts(p.prod_term__0, p.prod_term__1)
+}
+
+var (
+	re0 = regexp.MustCompile(`^[\+\-]`)
+	re1 = regexp.MustCompile(`^\d+`)
+	re2 = regexp.MustCompile(`^[\*\/]`)
+)
+
+func conv1(v any) default_grammar.BinOp {
+	if t, ok := v.(default_grammar.BinOp); ok || v == nil {
+		return t
+	}
+	if isZero(v) {
+		var zero default_grammar.BinOp
+		return zero
+	}
+	panic(fmt.Sprintf("can't convert %T to default_grammar.BinOp", v))
+}
+
+func conv0(v any) []default_grammar.BinOp {
+	if t, ok := v.([]default_grammar.BinOp); ok || v == nil {
+		return t
+	}
+	if l, ok := v.([]any); ok && l != nil {
+		out := make([]default_grammar.BinOp, len(l))
+		for i, x := range l {
+			out[i] = conv1(x)
+		}
+		return out
+	}
+	if isZero(v) {
+		var zero []default_grammar.BinOp
+		return zero
+	}
+	panic(fmt.Sprintf("can't convert %T to []default_grammar.BinOp", v))
+}
+
+func conv2(v any) string {
+	if t, ok := v.(string); ok || v == nil {
+		return t
+	}
+	if isZero(v) {
+		var zero string
+		return zero
+	}
+	panic(fmt.Sprintf("can't convert %T to string", v))
+}
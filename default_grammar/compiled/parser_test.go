@@ -0,0 +1,82 @@
+package compiled
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+	"github.com/ohait/parse-rec-descent-go/default_grammar"
+)
+
+func newParser() *Parser {
+	leftAssoc := func(op any, tail []default_grammar.BinOp) any {
+		for _, t := range tail {
+			t.Left = op
+			op = t
+		}
+		return op
+	}
+	assocTail := func(op string, right any, tail []default_grammar.BinOp) []default_grammar.BinOp {
+		return append([]default_grammar.BinOp{{Op: op, Right: right}}, tail...)
+	}
+	return &Parser{Actions: Actions{
+		Expr_0:   leftAssoc,
+		Expr__0:  assocTail,
+		Term_0:   leftAssoc,
+		Term__0:  assocTail,
+		Factor_0: func(e any) any { return e },
+	}}
+}
+
+func TestCompiled(t *testing.T) {
+	g := default_grammar.New()
+	p := newParser()
+	for _, in := range []string{"1", "1+2*3", "(1+2)*3", "8/4/2-1", "((7))"} {
+		exp, _, err := g.Parse("expr", []byte(in))
+		test.NoError(t, err)
+		got, err := p.ParseExpr("", []byte(in))
+		test.NoError(t, err)
+		expJSON, _ := json.Marshal(exp)
+		gotJSON, _ := json.Marshal(got)
+		test.EqualsGo(t, string(expJSON), string(gotJSON))
+	}
+	for _, in := range []string{"1+", "(1", "1)", "x"} {
+		_, _, exp := g.Parse("expr", []byte(in))
+		test.Error(t, exp)
+		_, err := p.ParseExpr("", []byte(in))
+		test.Error(t, err)
+		test.EqualsGo(t, exp.Error(), err.Error())
+	}
+}
+
+// the generated code must be up to date, run `go generate` otherwise
+func TestCompiledUpToDate(t *testing.T) {
+	src, err := default_grammar.New().GoSource(parse.GoOptions{
+		Package: "compiled",
+		PkgPath: "github.com/ohait/parse-rec-descent-go/default_grammar/compiled",
+	})
+	test.NoError(t, err)
+	old, err := os.ReadFile("parser.go")
+	test.NoError(t, err)
+	if string(src) != string(old) {
+		t.Fatalf("parser.go is out of date, run `go generate`")
+	}
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	p := newParser()
+	in := []byte("1+2*3-(4/5+6)*7")
+	for i := 0; i < b.N; i++ {
+		_, _ = p.ParseExpr("", in)
+	}
+}
+
+func BenchmarkRuntime(b *testing.B) {
+	g := default_grammar.New()
+	in := []byte("1+2*3-(4/5+6)*7")
+	for i := 0; i < b.N; i++ {
+		_, _, _ = g.Parse("expr", in)
+	}
+}
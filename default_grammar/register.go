@@ -0,0 +1,9 @@
+package default_grammar
+
+import (
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func init() {
+	parse.Register("default", New)
+}
@@ -0,0 +1,135 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+)
+
+// what went wrong, see Error
+// kinds are errors too, so `errors.Is(err, parse.Unparsed)` can be used
+type ErrorKind int
+
+const (
+	Syntax       ErrorKind = iota // the input doesn't match the grammar
+	Unparsed                      // the input matched, but there is text left
+	UnknownRule                   // the rule to parse doesn't exist
+	ActionFailed                  // a Return() function failed, Err is its error
+	Limit                         // the input is too deep, see Grammar.MaxDepth
+)
+
+func (this ErrorKind) String() string {
+	switch this {
+	case Syntax:
+		return "syntax"
+	case Unparsed:
+		return "unparsed"
+	case UnknownRule:
+		return "unknown_rule"
+	case ActionFailed:
+		return "action_failed"
+	case Limit:
+		return "limit"
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(this))
+}
+
+func (this ErrorKind) Error() string { return this.String() }
+
+func (this ErrorKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.String())
+}
+
+// the error returned when parsing, use errors.As() to get it
+type Error struct {
+	Kind ErrorKind
+
+	// the text which failed: from the start of the production (or where the error is) up to the error
+	Pos Pos
+
+	// the innermost rule being parsed, internal ones excluded
+	Rule string
+
+	// what could have matched where the parse stopped, see Stats.Expected
+	Expected []string
+
+	// the keywords close to the word where the parse stopped, closest first, for typos like `selct`
+	Suggestions []string
+
+	// the cause, like the error of the Return() function
+	Err error
+
+	commit bool
+	rules  *ruleFrame // the rules being parsed
+	custom bool       // the message was given by the grammar, see Prod.Expect()
+}
+
+var (
+	_ json.Marshaler = &Error{}
+	_ enc.Marshaler  = &Error{}
+)
+
+func (this Error) Error() string {
+	if hint := this.Hint(); hint != "" {
+		return fmt.Sprintf("%v at %d, %s", this.Err, this.Pos.End, hint)
+	}
+	return fmt.Sprintf("%v at %d", this.Err, this.Pos.End)
+}
+
+func (this Error) Unwrap() error { return this.Err }
+
+// errors.Is() matches the Kind, the cause is matched using Unwrap()
+func (this Error) Is(target error) bool {
+	k, ok := target.(ErrorKind)
+	return ok && k == this.Kind
+}
+
+// offset in the input where the error happened
+func (this Error) At() int { return this.Pos.End }
+
+// the error as an object, the fields are always in the same order and empty ones are omitted:
+//
+//	{"kind":"syntax","message":"expected /\\d+/ got \"x\"","file":"a.x","line":1,"col":5,"from":4,"at":4,
+//	 "rule":"value","expected":["/\\d+/"]}
+func (this *Error) MarshalJSON() ([]byte, error) {
+	line, col := this.Pos.Src.LineCol(this.Pos.End)
+	msg := ""
+	if this.Err != nil {
+		msg = this.Err.Error()
+	}
+	return json.Marshal(struct {
+		Kind        ErrorKind `json:"kind"`
+		Message     string    `json:"message"`
+		File        string    `json:"file,omitempty"`
+		Line        int       `json:"line,omitempty"`
+		Col         int       `json:"col,omitempty"`
+		From        int       `json:"from"`
+		At          int       `json:"at"`
+		Rule        string    `json:"rule,omitempty"`
+		Rules       []string  `json:"rules,omitempty"`
+		Expected    []string  `json:"expected,omitempty"`
+		Suggestions []string  `json:"suggestions,omitempty"`
+	}{this.Kind, msg, this.Pos.File, line, col, this.Pos.From, this.Pos.End, this.Rule, this.rules.names(),
+		this.Expected, this.Suggestions})
+}
+
+func (this *Error) MarshalNode(c ctx.C) (enc.Node, error) {
+	return enc.String(this.Error()), nil
+}
+
+// the error as returned to the caller, with the Rule, what was expected where the parse stopped and the suggestions
+func (this *Error) finish(s Stats) *Error {
+	e := *this // the error may be shared with the memo
+	if rules := e.rules.names(); len(rules) > 0 && e.Rule == "" {
+		e.Rule = rules[len(rules)-1]
+	}
+	if e.Pos.Src != nil && s.Farthest >= e.Pos.End && s.Farthest <= len(e.Pos.Src.bytes) &&
+		strings.TrimSpace(string(e.Pos.Src.bytes[e.Pos.End:s.Farthest])) == "" {
+		e.Expected = s.Expected // the error is where the parse stopped, give or take the whitespaces
+	}
+	e.Suggestions = suggestions(e.Pos.Src, s)
+	return &e
+}
@@ -0,0 +1,140 @@
+package parse_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func errorGrammar(t *testing.T) *parse.Grammar {
+	g, err := parse.LoadGrammar("x.prd", []byte(`%ws /\s*/
+stmt: "let" name "=" value ";"
+name: /[a-z]+/
+value: /\d+/
+     | "(" value ")"
+`))
+	test.NoError(t, err)
+	return g
+}
+
+func TestErrorKinds(t *testing.T) {
+	g := errorGrammar(t)
+	var perr *parse.Error
+
+	_, _, err := g.ParseFile("stmt", "a.x", []byte(`let x = y;`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, parse.Syntax, perr.Kind)
+	test.Assert(t, errors.Is(err, parse.Syntax))
+	test.Assert(t, !errors.Is(err, parse.Unparsed))
+	test.EqualsGo(t, 8, perr.Pos.From)
+	test.EqualsGo(t, 8, perr.Pos.End)
+	test.EqualsGo(t, "a.x", perr.Pos.File)
+	test.EqualsGo(t, "value", perr.Rule)
+	test.EqualsGo(t, []string{`/\d+/`, `"("`}, perr.Expected)
+
+	_, _, err = g.Parse("stmt", []byte(`let x = 1; 2`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, parse.Unparsed, perr.Kind)
+	test.Assert(t, errors.Is(err, parse.Unparsed))
+	test.EqualsGo(t, `unparsed: " 2" at 10`, err.Error())
+
+	// also when parsing with a single production
+	_, err = g.Alt("stmt").Prods()[0].Parse("a.x", []byte(`let x = 1; 2`), nil)
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, parse.Unparsed, perr.Kind)
+	test.EqualsGo(t, `unparsed: " 2" at 10`, err.Error())
+	_, err = g.Alt("stmt").Prods()[0].Parse("a.x", []byte(`let x = y;`), nil)
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, parse.Syntax, perr.Kind)
+	test.EqualsGo(t, "value", perr.Rule)
+	test.EqualsGo(t, []string{`/\d+/`, `"("`}, perr.Expected)
+
+	_, _, err = g.Parse("nope", []byte(`let x = 1;`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, parse.UnknownRule, perr.Kind)
+	test.EqualsGo(t, "nope", perr.Rule)
+	_, err = g.CompletionsAt("nope", nil, 0)
+	test.Assert(t, errors.Is(err, parse.UnknownRule))
+
+	// also when the rule has no productions, or is referred to while parsing
+	g.Alt("empty").Label("nothing")
+	_, _, err = g.Parse("empty", []byte(`let x = 1;`))
+	test.Assert(t, errors.Is(err, parse.UnknownRule))
+	_, err = g.CompletionsAt("empty", nil, 0)
+	test.Assert(t, errors.Is(err, parse.UnknownRule))
+	g.Add("ref", `"let" missing`)
+	_, _, err = g.Parse("ref", []byte(`let x = 1;`))
+	test.Assert(t, errors.As(err, &perr))
+	test.Assert(t, errors.Is(err, parse.UnknownRule))
+	test.EqualsGo(t, "missing", perr.Rule)
+
+	// the error of the action is the cause
+	errOdd := errors.New("odd")
+	g.Alt("value").Prods()[0].Return(func(s string) (string, error) {
+		if strings.Contains("13579", s[len(s)-1:]) {
+			return "", errOdd
+		}
+		return s, nil
+	})
+	_, _, err = g.Parse("stmt", []byte(`let x = 7;`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, parse.ActionFailed, perr.Kind)
+	test.Assert(t, errors.Is(err, errOdd))
+	test.Assert(t, errors.Is(err, parse.ActionFailed))
+}
+
+func TestErrorLimit(t *testing.T) {
+	g := errorGrammar(t)
+	g.MaxDepth = 10
+	_, _, err := g.Parse("stmt", []byte(`let x = (((2)));`))
+	test.NoError(t, err)
+
+	text := `let x = ` + strings.Repeat("(", 20) + "2" + strings.Repeat(")", 20) + ";"
+	_, _, err = g.Parse("stmt", []byte(text))
+	test.Assert(t, errors.Is(err, parse.Limit))
+	test.Contains(t, err.Error(), "more than 10 nested rules")
+
+	// also when reparsing
+	tree, err := g.ParseTree("stmt", "", []byte(text))
+	test.Assert(t, errors.Is(err, parse.Limit))
+	tree.Edit(9, 10, 9)
+	_, _, err = tree.Reparse([]byte(text[:9] + text[10:len(text)-2] + ";"))
+	test.Assert(t, errors.Is(err, parse.Limit))
+
+	g.MaxDepth = 0
+	_, _, err = g.Parse("stmt", []byte(text))
+	test.NoError(t, err)
+
+	// a deep input stops at the limit, without growing the stack
+	g.MaxDepth = 1000
+	text = `let x = ` + strings.Repeat("(", 1_000_000) + "2;"
+	_, _, err = g.Parse("stmt", []byte(text))
+	var perr *parse.Error
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, parse.Limit, perr.Kind)
+	test.Assert(t, perr.Pos.End < 8+1000)
+}
+
+func TestErrorJSON(t *testing.T) {
+	g := errorGrammar(t)
+	_, _, err := g.ParseFile("stmt", "a.x", []byte("let x =\n  y;"))
+	j, jerr := json.Marshal(err)
+	test.NoError(t, jerr)
+	test.EqualsGo(t, `{"kind":"syntax","message":"expected /^\\d+/ got \"y;\"","file":"a.x","line":2,"col":3,`+
+		`"from":10,"at":10,"rule":"value","rules":["stmt","value"],"expected":["/\\d+/","\"(\""]}`, string(j))
+
+	_, _, err = g.Parse("stmt", []byte(`lte x = 1;`))
+	j, jerr = json.Marshal(err)
+	test.NoError(t, jerr)
+	test.EqualsGo(t, `{"kind":"syntax","message":"expected /^let/ got \"lte x = 1;\"","line":1,"col":1,`+
+		`"from":0,"at":0,"rule":"stmt","rules":["stmt"],"expected":["\"let\""],"suggestions":["let"]}`, string(j))
+
+	for _, k := range []parse.ErrorKind{parse.Syntax, parse.Unparsed, parse.UnknownRule, parse.ActionFailed, parse.Limit} {
+		j, _ := json.Marshal(k)
+		test.EqualsGo(t, `"`+k.String()+`"`, string(j))
+	}
+}
@@ -0,0 +1,115 @@
+package parse_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func TestExpect(t *testing.T) {
+	var g parse.Grammar
+	g.Add("expr", `term /[\+\-]/ expr`)
+	g.Add("expr", `term`)
+	g.Add("term", `"(" + expr ")"`).Expect("a closing parenthesis")
+	g.Add("term", `/\d+/`)
+	test.NoError(t, g.Verify())
+
+	_, _, err := g.Parse("expr", []byte(`(1+2`))
+	test.Error(t, err)
+	test.EqualsGo(t, `expected a closing parenthesis got "" at 4`, err.Error())
+
+	// the sub rules keep their own errors, wrapped by the commit
+	_, _, err = g.Parse("expr", []byte(`(+`))
+	test.EqualsGo(t, `expected a closing parenthesis got "+" at 1`, err.Error())
+
+	// and it's what is expected
+	_, stats, _ := g.Parse("expr", []byte(`(1*`))
+	test.EqualsGo(t, []string{`/[\+\-]/`, "a closing parenthesis"}, stats.Expected)
+}
+
+func TestLabel(t *testing.T) {
+	var g parse.Grammar
+	g.Add("stmt", `name "=" value ";" <eof>`)
+	g.Add("name", `/[a-z]+/`)
+	g.Alt("value").Label("a value")
+	g.Add("value", `/\d+/`)
+	g.Add("value", `"'" /[^']*/ "'"`)
+	g.Add("value", `"[" [ value(s ",") ] "]"`)
+	test.NoError(t, g.Verify())
+
+	_, stats, err := g.Parse("stmt", []byte(`x=;`))
+	test.Error(t, err)
+	test.EqualsGo(t, `expected a value got ";" at 2`, err.Error())
+	test.EqualsGo(t, 2, stats.Farthest)
+	test.EqualsGo(t, []string{"a value"}, stats.Expected)
+
+	// once something was matched, the errors are the ones of the alternatives
+	_, stats, err = g.Parse("stmt", []byte(`x='abc;`))
+	test.Error(t, err)
+	test.NotContains(t, err.Error(), "a value")
+	test.EqualsGo(t, []string{`"'"`}, stats.Expected)
+
+	// including the labels of the nested rules
+	_, stats, _ = g.Parse("stmt", []byte(`x=[1,];`))
+	test.EqualsGo(t, 5, stats.Farthest)
+	test.EqualsGo(t, []string{"a value"}, stats.Expected)
+
+	// the label is also expected after a successful match which stopped there
+	_, stats, _ = g.Parse("stmt", []byte(`x=[1 2];`))
+	test.EqualsGo(t, []string{`","`, `"]"`}, stats.Expected)
+}
+
+func TestErrorDirective(t *testing.T) {
+	var g parse.Grammar
+	g.Add("top", `expr <eof>`)
+	g.Add("expr", `term /[\+\-]/+ expr`)
+	g.Add("expr", `term`)
+	g.Add("term", `"(" + expr close`)
+	g.Add("term", `/\d+/`)
+	g.Add("term", `<error:expected a number or a parenthesis>`)
+	g.Add("close", `")"`)
+	g.Add("close", `<error:unclosed parenthesis>`)
+	test.NoError(t, g.Verify())
+
+	_, _, err := g.Parse("top", []byte(`1+x`))
+	test.Error(t, err)
+	test.EqualsGo(t, `expected a number or a parenthesis at 2`, err.Error())
+
+	// it's committed like any other error
+	_, _, err = g.Parse("top", []byte(`(1+2`))
+	test.EqualsGo(t, `unclosed parenthesis at 4`, err.Error())
+
+	// it's not expected
+	_, stats, _ := g.Parse("top", []byte(`1+x`))
+	test.EqualsGo(t, []string{`"("`, `/\d+/`}, stats.Expected)
+
+	// and never generated
+	for i := 0; i < 20; i++ {
+		out, err := g.Generate("top", rand.New(rand.NewSource(int64(i))), parse.GenerateOptions{})
+		test.NoError(t, err)
+		_, _, err = g.Parse("top", out)
+		test.NoError(t, err)
+	}
+
+	_, err = parse.LoadGrammar("x.prd", []byte(`x: <error>`))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "expected a message")
+}
+
+func TestLabelPragma(t *testing.T) {
+	g, err := parse.LoadGrammar("x.prd", []byte(`%ws /\s*/
+%label num a number
+sum: num "+" num <eof>
+num: /\d+/
+`))
+	test.NoError(t, err)
+	_, _, err = g.Parse("sum", []byte(`1 + x`))
+	test.Error(t, err)
+	test.EqualsGo(t, `expected a number got "x" at 4`, err.Error())
+
+	_, err = parse.LoadGrammar("x.prd", []byte("%label num\nnum: /\\d+/\n"))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "expected `%label rule label`")
+}
@@ -0,0 +1,372 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// the structure of a directive, with the internal productions folded back
+type expr struct {
+	op   exprOp
+	text string // literal, regexp, rule name or builtin
+	sub  []*expr
+}
+
+type exprOp int
+
+const (
+	exprEmpty   exprOp = iota
+	exprSeq            // a b
+	exprChoice         // a | b
+	exprLit            // "a"
+	exprClass          // [a-z], only in regexp conversion
+	exprRE             // /a+/
+	exprRef            // name
+	exprKind           // token kind
+	exprBuiltin        // <eof>
+	exprNot            // !a
+	exprAnd            // &a
+	exprOpt            // a?
+	exprPlus           // a+
+	exprStar           // a*
+)
+
+// the public rules, in the order they were added
+func (this *Grammar) ruleNames() []string {
+	var names []string
+	for name, alt := range this.alts {
+		if alt.internal == "" && len(alt.prods) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := this.alts[names[i]].prods[0].src, this.alts[names[j]].prods[0].src
+		if a == b {
+			return names[i] < names[j]
+		}
+		return srcLess(a, b)
+	})
+	return names
+}
+
+func (this *Grammar) ruleExpr(name string) *expr {
+	var list []*expr
+	empty := false
+	for _, p := range this.alts[name].prods {
+		e := this.prodExpr(p)
+		if e.op == exprEmpty {
+			empty = true
+			continue
+		}
+		list = append(list, e)
+	}
+	var e *expr
+	switch len(list) {
+	case 0:
+		return &expr{op: exprEmpty}
+	case 1:
+		e = list[0]
+	default:
+		e = &expr{op: exprChoice, sub: list}
+	}
+	if empty {
+		e = &expr{op: exprOpt, sub: []*expr{e}}
+	}
+	return e
+}
+
+func (this *Grammar) prodExpr(p *Prod) *expr {
+	var list []*expr
+	for _, act := range p.actions {
+		if act.commit {
+			continue
+		}
+		e := this.actionExpr(act)
+		if e.op == exprSeq {
+			list = append(list, e.sub...) // splice folded repetitions
+		} else {
+			list = append(list, e)
+		}
+	}
+	switch len(list) {
+	case 0:
+		return &expr{op: exprEmpty}
+	case 1:
+		return list[0]
+	default:
+		return &expr{op: exprSeq, sub: list}
+	}
+}
+
+func (this *Grammar) actionExpr(act action) *expr {
+	var e *expr
+	switch {
+	case act.builtin != "":
+		e = &expr{op: exprBuiltin, text: act.builtin}
+	case act.lit != "":
+		e = &expr{op: exprLit, text: act.lit}
+	case act.re != nil:
+		e = &expr{op: exprRE, text: strings.TrimPrefix(act.re.String(), "^")}
+	case this.isKind(act.prod):
+		e = &expr{op: exprKind, text: act.prod}
+	default:
+		alt := this.alts[act.prod]
+		switch {
+		case alt != nil && alt.internal == "rep":
+			p1 := alt.prods[0] // `item rep_`
+			item := this.actionExpr(p1.actions[0])
+			p2 := this.alts[p1.actions[1].prod].prods[0] // `sep item rep_` or `item rep_`
+			if len(p2.actions) == 3 {
+				sep := this.actionExpr(p2.actions[0])
+				tail := &expr{op: exprSeq, sub: []*expr{sep, item}}
+				e = &expr{op: exprSeq, sub: []*expr{item, {op: exprStar, sub: []*expr{tail}}}}
+			} else {
+				e = &expr{op: exprPlus, sub: []*expr{item}}
+			}
+		case alt != nil && alt.internal == "opt":
+			e = &expr{op: exprOpt, sub: []*expr{this.prodExpr(alt.prods[0])}}
+		default:
+			e = &expr{op: exprRef, text: act.prod}
+		}
+	}
+	if act.negative {
+		e = &expr{op: exprNot, sub: []*expr{e}}
+	}
+	if act.ahead {
+		e = &expr{op: exprAnd, sub: []*expr{e}}
+	}
+	return e
+}
+
+// export the grammar in W3C EBNF notation
+// regexp terminals are converted where possible, lookaheads and builtins are added as comments
+func (this *Grammar) EBNF() string {
+	var b strings.Builder
+	names := this.ruleNames()
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	if this.Lexer != nil {
+		for _, r := range this.Lexer.rules {
+			width = max(width, len(r.kind))
+		}
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "%-*s ::= %s\n", width, name, ebnf(this.ruleExpr(name), 0))
+	}
+	if this.Lexer != nil {
+		for _, r := range this.Lexer.rules {
+			fmt.Fprintf(&b, "%-*s ::= %s\n", width, r.kind, ebnf(reExpr(r.re), 0))
+		}
+	}
+	return b.String()
+}
+
+// precedence: 0 choice, 1 sequence, 2 postfix
+func ebnf(e *expr, prec int) string {
+	paren := func(s string, p int) string {
+		if p < prec {
+			return "( " + s + " )"
+		}
+		return s
+	}
+	switch e.op {
+	case exprEmpty:
+		return "/* empty */"
+	case exprSeq:
+		var list []string
+		for _, s := range e.sub {
+			list = append(list, ebnf(s, 2))
+		}
+		return paren(strings.Join(list, " "), 1)
+	case exprChoice:
+		var list []string
+		for _, s := range e.sub {
+			list = append(list, ebnf(s, 1))
+		}
+		return paren(strings.Join(list, " | "), 0)
+	case exprLit:
+		if strings.Contains(e.text, `"`) {
+			return "'" + e.text + "'"
+		}
+		return `"` + e.text + `"`
+	case exprClass, exprRef, exprKind:
+		return e.text
+	case exprRE:
+		re, err := regexp.Compile(e.text)
+		if err != nil {
+			return "/* /" + e.text + "/ */"
+		}
+		return ebnf(reExpr(re), prec)
+	case exprBuiltin:
+		return "/* " + e.text + " */"
+	case exprNot:
+		return "/* !" + ebnf(e.sub[0], 2) + " */"
+	case exprAnd:
+		return "/* &" + ebnf(e.sub[0], 2) + " */"
+	case exprOpt:
+		return ebnf(e.sub[0], 2) + "?"
+	case exprPlus:
+		return ebnf(e.sub[0], 2) + "+"
+	case exprStar:
+		return ebnf(e.sub[0], 2) + "*"
+	}
+	return ""
+}
+
+// convert a regexp into an expression
+func reExpr(re *regexp.Regexp) *expr {
+	parsed, err := syntax.Parse(strings.TrimPrefix(re.String(), "^"), syntax.Perl)
+	if err != nil {
+		return &expr{op: exprRE, text: re.String()}
+	}
+	return syntaxExpr(parsed.Simplify())
+}
+
+func syntaxExpr(re *syntax.Regexp) *expr {
+	subs := func() []*expr {
+		var list []*expr
+		for _, s := range re.Sub {
+			if e := syntaxExpr(s); e.op != exprEmpty {
+				list = append(list, e)
+			}
+		}
+		return list
+	}
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			return &expr{op: exprLit, text: string(re.Rune)}
+		}
+		var list []*expr
+		for _, r := range re.Rune {
+			lo, up := unicode.ToLower(r), unicode.ToUpper(r)
+			if lo == up {
+				list = append(list, &expr{op: exprLit, text: string(r)})
+			} else {
+				list = append(list, &expr{op: exprClass, text: "[" + string(up) + string(lo) + "]"})
+			}
+		}
+		if len(list) == 1 {
+			return list[0]
+		}
+		return &expr{op: exprSeq, sub: list}
+	case syntax.OpCharClass:
+		return &expr{op: exprClass, text: ebnfClass(re.Rune)}
+	case syntax.OpAnyCharNotNL:
+		return &expr{op: exprClass, text: "[^#xA]"}
+	case syntax.OpAnyChar:
+		return &expr{op: exprClass, text: "[#x0-#x10FFFF]"}
+	case syntax.OpCapture:
+		return syntaxExpr(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		list := subs()
+		if len(list) == 0 {
+			return &expr{op: exprEmpty}
+		}
+		op := map[syntax.Op]exprOp{syntax.OpStar: exprStar, syntax.OpPlus: exprPlus, syntax.OpQuest: exprOpt}[re.Op]
+		return &expr{op: op, sub: list}
+	case syntax.OpRepeat:
+		item := syntaxExpr(re.Sub[0])
+		var list []*expr
+		for i := 0; i < re.Min; i++ {
+			list = append(list, item)
+		}
+		switch {
+		case re.Max < 0:
+			list = append(list, &expr{op: exprStar, sub: []*expr{item}})
+		default:
+			for i := re.Min; i < re.Max; i++ {
+				list = append(list, &expr{op: exprOpt, sub: []*expr{item}})
+			}
+		}
+		if len(list) == 1 {
+			return list[0]
+		}
+		return &expr{op: exprSeq, sub: list}
+	case syntax.OpConcat:
+		list := subs()
+		switch len(list) {
+		case 0:
+			return &expr{op: exprEmpty}
+		case 1:
+			return list[0]
+		}
+		return &expr{op: exprSeq, sub: list}
+	case syntax.OpAlternate:
+		return &expr{op: exprChoice, sub: subs()}
+	}
+	return &expr{op: exprEmpty} // empty match and assertions
+}
+
+// W3C character class, negated if it's shorter
+func ebnfClass(ranges []rune) string {
+	neg := ""
+	if len(ranges) > 0 && ranges[0] == 0 && ranges[len(ranges)-1] == unicode.MaxRune {
+		neg = "^"
+		var comp []rune
+		for i := 1; i+1 < len(ranges); i += 2 {
+			comp = append(comp, ranges[i]+1, ranges[i+1]-1)
+		}
+		ranges = comp
+	}
+	char := func(r rune) string {
+		if r > ' ' && r < 0x7f && !strings.ContainsRune(`[]^-\#`, r) {
+			return string(r)
+		}
+		return fmt.Sprintf("#x%X", r)
+	}
+	var b strings.Builder
+	b.WriteString("[" + neg)
+	for i := 0; i+1 < len(ranges); i += 2 {
+		b.WriteString(char(ranges[i]))
+		if ranges[i+1] != ranges[i] {
+			b.WriteString("-" + char(ranges[i+1]))
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// export the dependencies between the rules as a Graphviz DOT graph
+func (this *Grammar) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph grammar {\n\trankdir=LR;\n\tnode [shape=ellipse];\n")
+	kinds := map[string]bool{}
+	for _, name := range this.ruleNames() {
+		fmt.Fprintf(&b, "\t%q;\n", name)
+		seen := map[string]bool{}
+		var walk func(e *expr)
+		walk = func(e *expr) {
+			switch e.op {
+			case exprRef, exprKind:
+				if e.op == exprKind {
+					kinds[e.text] = true
+				}
+				if !seen[e.text] {
+					seen[e.text] = true
+					fmt.Fprintf(&b, "\t%q -> %q;\n", name, e.text)
+				}
+			}
+			for _, s := range e.sub {
+				walk(s)
+			}
+		}
+		walk(this.ruleExpr(name))
+	}
+	var list []string
+	for k := range kinds {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	for _, k := range list {
+		fmt.Fprintf(&b, "\t%q [shape=box];\n", k)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func exportGrammar() *Grammar {
+	var g Grammar
+	g.Add("list", `"(" item(s ",") ")"`)
+	g.Add("item", `/[a-z]+/ [ "=" num ]`)
+	g.Add("item", `!"x" num(s)`)
+	g.Add("num", `/\d+/ <eol>`)
+	return &g
+}
+
+func TestEBNF(t *testing.T) {
+	out := exportGrammar().EBNF()
+	t.Logf("\n%s", out)
+	test.EqualsGo(t, strings.Join([]string{
+		`list ::= "(" item ( "," item )* ")"`,
+		`item ::= [a-z]+ ( "=" num )? | /* !"x" */ num+`,
+		`num  ::= [0-9]+ /* <eol> */`,
+		``,
+	}, "\n"), out)
+}
+
+func TestEBNFLexer(t *testing.T) {
+	g := Grammar{Lexer: sqlLexer()}
+	g.Add("select", `"select" IDENT(s ",")`)
+	out := g.EBNF()
+	t.Logf("\n%s", out)
+	test.Contains(t, out, `select ::= "select" IDENT ( "," IDENT )*`)
+	test.Contains(t, out, "IDENT  ::= ")
+}
+
+func TestDOT(t *testing.T) {
+	out := exportGrammar().DOT()
+	t.Logf("\n%s", out)
+	test.Contains(t, out, `"list" -> "item";`)
+	test.Contains(t, out, `"item" -> "num";`)
+	test.NotContains(t, out, `rep`)
+	test.EqualsGo(t, 1, strings.Count(out, `"item" -> "num"`))
+}
+
+func TestRailroad(t *testing.T) {
+	out := exportGrammar().Railroad()
+	test.EqualsGo(t, 3, strings.Count(out, "<svg "))
+	test.Contains(t, out, `<h2 id="rule-item">item</h2>`)
+	test.Contains(t, out, `<a href="#rule-num">`)
+	test.Contains(t, out, `&#34;,&#34;`)
+}
@@ -0,0 +1,85 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the value as an indented tree, with the lists as `- item` and the maps as sorted `key: value`
+// the value is first encoded as JSON, so Pos and the custom types are shown the way they marshal
+func Indented(out any) (string, error) {
+	j, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	var v any
+	if err := json.Unmarshal(j, &v); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	indented(&sb, "", v)
+	return sb.String(), nil
+}
+
+// print the decoded JSON value
+func indented(w *strings.Builder, indent string, v any) {
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 0 {
+			fmt.Fprintf(w, "%s[]\n", indent)
+		}
+		for _, e := range v {
+			if isScalar(e) {
+				fmt.Fprintf(w, "%s- %s\n", indent, scalar(e))
+			} else {
+				fmt.Fprintf(w, "%s-\n", indent)
+				indented(w, indent+"  ", e)
+			}
+		}
+	case map[string]any:
+		if len(v) == 0 {
+			fmt.Fprintf(w, "%s{}\n", indent)
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if isScalar(v[k]) {
+				fmt.Fprintf(w, "%s%s: %s\n", indent, k, scalar(v[k]))
+			} else {
+				fmt.Fprintf(w, "%s%s:\n", indent, k)
+				indented(w, indent+"  ", v[k])
+			}
+		}
+	default:
+		fmt.Fprintf(w, "%s%s\n", indent, scalar(v))
+	}
+}
+
+func isScalar(v any) bool {
+	switch v := v.(type) {
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return true
+}
+
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []any:
+		return "[]"
+	case map[string]any:
+		return "{}"
+	}
+	return fmt.Sprint(v)
+}
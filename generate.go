@@ -0,0 +1,369 @@
+package parse
+
+import (
+	"math"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+type GenerateOptions struct {
+	// after this depth, the alternatives with the shortest derivation are chosen (default 20)
+	MaxDepth int
+
+	// maximum repetitions for `*`, `+` and `{n,}` in regexp terminals (default 3)
+	MaxRepeat int
+
+	// added for each `<indent>` level (default "  ")
+	Indent string
+
+	// how many inputs to generate before giving up finding one which parses (default 10)
+	Tries int
+}
+
+// generate a random input which can be parsed by the given production
+// alternatives are chosen according to Prod.Weight, and regexp terminals are expanded into random strings
+// since lookaheads and actions can't be generated, each input is parsed and discarded if it fails
+func (this *Grammar) Generate(start string, rnd *rand.Rand, opts GenerateOptions) ([]byte, error) {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 20
+	}
+	if opts.MaxRepeat <= 0 {
+		opts.MaxRepeat = 3
+	}
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+	if opts.Tries <= 0 {
+		opts.Tries = 10
+	}
+	if this.alts[start] == nil {
+		return nil, ctx.NewErrorf(nil, "no prod named %q", start)
+	}
+	gen := generator{
+		g:     this,
+		rnd:   rnd,
+		opts:  opts,
+		depth: this.minDepths(),
+		res:   map[*regexp.Regexp]*syntax.Regexp{},
+	}
+	var err error
+	for i := 0; i < opts.Tries; i++ {
+		gen.out = gen.out[0:0]
+		gen.level = 0
+		gen.eol = false
+		if err = gen.alt(start, 0); err != nil {
+			return nil, err
+		}
+		if gen.eol {
+			gen.out = append(gen.out, '\n')
+		}
+		_, _, err = this.Parse(start, gen.out)
+		if err == nil {
+			return append([]byte(nil), gen.out...), nil
+		}
+	}
+	return nil, ctx.NewErrorf(nil, "can't generate a valid %q after %d tries: %v", start, opts.Tries, err)
+}
+
+// generate n inputs, to be used as seed corpus with testing.F.Add()
+func (this *Grammar) Corpus(start string, n int, rnd *rand.Rand, opts GenerateOptions) ([][]byte, error) {
+	var list [][]byte
+	for i := 0; i < n; i++ {
+		in, err := this.Generate(start, rnd, opts)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, in)
+	}
+	return list, nil
+}
+
+// for each alternation, the minimum depth needed to generate it
+func (this *Grammar) minDepths() map[string]int {
+	depth := map[string]int{}
+	for changed := true; changed; {
+		changed = false
+		for name, alt := range this.alts {
+			best := math.MaxInt
+			for _, p := range alt.prods {
+				best = min(best, this.prodDepth(depth, p))
+			}
+			if cur, ok := depth[name]; best != math.MaxInt && (!ok || best < cur) {
+				depth[name] = best
+				changed = true
+			}
+		}
+	}
+	return depth
+}
+
+// the minimum depth needed to generate the production, given the depths of the alternations
+func (this *Grammar) prodDepth(depth map[string]int, p *Prod) int {
+	if p.fails() {
+		return math.MaxInt
+	}
+	d := 0
+	for _, act := range p.actions {
+		if act.prod == "" || act.negative || act.ahead || this.alts[act.prod] == nil && this.Lexer.Has(act.prod) {
+			continue
+		}
+		sub, ok := depth[act.prod]
+		if !ok {
+			return math.MaxInt
+		}
+		d = max(d, sub+1)
+	}
+	return d
+}
+
+type generator struct {
+	g     *Grammar
+	rnd   *rand.Rand
+	opts  GenerateOptions
+	depth map[string]int
+	res   map[*regexp.Regexp]*syntax.Regexp
+
+	out   []byte
+	level int
+	eol   bool // a newline is needed before anything else
+}
+
+func (this *generator) alt(name string, depth int) error {
+	alt := this.g.alts[name]
+	if alt == nil || len(alt.prods) == 0 {
+		if this.g.Lexer.Has(name) {
+			for _, r := range this.g.Lexer.rules {
+				if r.kind == name {
+					return this.re(nil, r.re)
+				}
+			}
+		}
+		return ctx.NewErrorf(nil, "no prod named %q", name)
+	}
+
+	// after MaxDepth, only the shortest alternatives are candidates
+	var candidates []*Prod
+	for _, p := range alt.prods {
+		if !p.fails() {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return ctx.NewErrorf(nil, "can't generate %q, all its productions fail", name)
+	}
+	if depth >= this.opts.MaxDepth {
+		candidates = nil
+		best := math.MaxInt
+		for _, p := range alt.prods {
+			d := this.g.prodDepth(this.depth, p)
+			switch {
+			case d < best:
+				best = d
+				candidates = []*Prod{p}
+			case d == best:
+				candidates = append(candidates, p)
+			}
+		}
+		if best == math.MaxInt {
+			return ctx.NewErrorf(nil, "can't generate %q, no finite derivation", name)
+		}
+	}
+
+	sum := 0.0
+	for _, p := range candidates {
+		sum += p.weight()
+	}
+	x := this.rnd.Float64() * sum
+	p := candidates[len(candidates)-1]
+	for _, c := range candidates {
+		x -= c.weight()
+		if x < 0 {
+			p = c
+			break
+		}
+	}
+	return this.prod(p, depth)
+}
+
+// the production contains `<error:...>`, so it never matches
+func (this *Prod) fails() bool {
+	for _, act := range this.actions {
+		if act.message != "" && !act.negative {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Prod) weight() float64 {
+	if this.Weight <= 0 {
+		return 1
+	}
+	return this.Weight
+}
+
+func (this *generator) prod(p *Prod, depth int) error {
+	for _, act := range p.actions {
+		switch {
+		case act.commit, act.negative, act.ahead:
+		case act.builtin != "":
+			this.builtin(act.builtin)
+		case act.lit != "":
+			this.emit(p, act.lit)
+		case act.re != nil:
+			if err := this.re(p, act.re); err != nil {
+				return err
+			}
+		case act.prod != "":
+			if err := this.alt(act.prod, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (this *generator) bol() bool {
+	return len(this.out) == 0 || this.out[len(this.out)-1] == '\n'
+}
+
+func (this *generator) builtin(b string) {
+	switch {
+	case b == "<eol>":
+		this.eol = true
+	case b == "<bol>":
+		if !this.bol() {
+			this.out = append(this.out, '\n')
+		}
+	case strings.HasPrefix(b, "<col:"):
+		col := 0
+		for _, c := range b[5 : len(b)-1] {
+			col = col*10 + int(c-'0')
+		}
+		line := this.out[strings.LastIndexByte(string(this.out), '\n')+1:]
+		for i := len(line) + 1; i < col; i++ {
+			this.out = append(this.out, ' ')
+		}
+	case b == "<indent>":
+		this.level++
+		if this.bol() {
+			this.out = append(this.out, strings.Repeat(this.opts.Indent, this.level)...)
+		}
+	case b == "<samedent>":
+		if this.bol() {
+			this.out = append(this.out, strings.Repeat(this.opts.Indent, this.level)...)
+		}
+	case b == "<dedent>":
+		this.level--
+	}
+}
+
+func (this *generator) emit(p *Prod, s string) {
+	if s == "" {
+		return
+	}
+	if this.eol && s[0] != '\n' {
+		this.out = append(this.out, '\n')
+	}
+	this.eol = false
+	if len(this.out) > 0 && !strings.ContainsRune(" \t\n", rune(this.out[len(this.out)-1])) && s[0] != '\n' && this.spaced(p) {
+		this.out = append(this.out, ' ')
+	}
+	this.out = append(this.out, s...)
+}
+
+// true if a space can be added before a terminal
+func (this *generator) spaced(p *Prod) bool {
+	if this.g.Lexer != nil {
+		return this.g.Lexer.Skip != nil && this.g.Lexer.Skip.FindString(" ") == " "
+	}
+	if p == nil {
+		return false
+	}
+	ws := p.ws()
+	return ws != nil && ws.FindString(" ") == " "
+}
+
+func (this *generator) re(p *Prod, re *regexp.Regexp) error {
+	parsed := this.res[re]
+	if parsed == nil {
+		var err error
+		parsed, err = syntax.Parse(strings.TrimPrefix(re.String(), "^"), syntax.Perl)
+		if err != nil {
+			return err
+		}
+		parsed = parsed.Simplify()
+		this.res[re] = parsed
+	}
+	var b strings.Builder
+	this.sample(&b, parsed)
+	this.emit(p, b.String())
+	return nil
+}
+
+// generate a random string matching the regexp
+func (this *generator) sample(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		b.WriteRune(this.class(re.Rune))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteRune(rune(' ' + this.rnd.Intn(95)))
+	case syntax.OpCapture:
+		this.sample(b, re.Sub[0])
+	case syntax.OpStar:
+		this.repeat(b, re.Sub[0], 0, this.opts.MaxRepeat)
+	case syntax.OpPlus:
+		this.repeat(b, re.Sub[0], 1, this.opts.MaxRepeat)
+	case syntax.OpQuest:
+		this.repeat(b, re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		hi := re.Max
+		if hi < 0 || hi > re.Min+this.opts.MaxRepeat {
+			hi = re.Min + this.opts.MaxRepeat
+		}
+		this.repeat(b, re.Sub[0], re.Min, hi)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			this.sample(b, sub)
+		}
+	case syntax.OpAlternate:
+		this.sample(b, re.Sub[this.rnd.Intn(len(re.Sub))])
+	default: // empty match and assertions
+	}
+}
+
+func (this *generator) repeat(b *strings.Builder, re *syntax.Regexp, lo, hi int) {
+	n := lo
+	if hi > lo {
+		n += this.rnd.Intn(hi - lo + 1)
+	}
+	for i := 0; i < n; i++ {
+		this.sample(b, re)
+	}
+}
+
+// pick a rune from the class, preferring printable ASCII
+func (this *generator) class(ranges []rune) rune {
+	in := func(r rune) bool {
+		for i := 0; i < len(ranges); i += 2 {
+			if r >= ranges[i] && r <= ranges[i+1] {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < 20; i++ {
+		r := rune(' ' + this.rnd.Intn(95))
+		if in(r) {
+			return r
+		}
+	}
+	i := this.rnd.Intn(len(ranges)/2) * 2
+	return ranges[i] + rune(this.rnd.Intn(int(ranges[i+1]-ranges[i]+1)))
+}
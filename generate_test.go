@@ -0,0 +1,66 @@
+package parse
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestGenerate(t *testing.T) {
+	var g Grammar
+	g.Add("list", `"(" item(s ",") ")"`).WS = Whitespaces
+	g.Add("item", `/[a-z]\w*/`).WS = Whitespaces
+	g.Add("item", `/-?\d+(\.\d+)?/`).WS = Whitespaces
+	g.Add("item", `list`).Weight = 0.2
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		in, err := g.Generate("list", rnd, GenerateOptions{MaxDepth: 4})
+		test.NoError(t, err)
+		t.Logf("%s", in)
+		_, _, err = g.Parse("list", in)
+		test.NoError(t, err)
+	}
+}
+
+func TestGenerateBuiltins(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	{
+		g := indentGrammar(t)
+		g.Log = nil
+		in, err := g.Generate("file", rnd, GenerateOptions{MaxDepth: 6})
+		test.NoError(t, err)
+		t.Logf("%q", in)
+	}
+	{
+		g := Grammar{Lexer: sqlLexer()}
+		g.Add("select", `"select" IDENT(s ",") "from" IDENT [ "where" IDENT "=" NUMBER ]`)
+		in, err := g.Generate("select", rnd, GenerateOptions{})
+		test.NoError(t, err)
+		t.Logf("%s", in)
+	}
+}
+
+func TestGenerateInfinite(t *testing.T) {
+	var g Grammar
+	g.Add("loop", `"(" loop ")"`)
+	_, err := g.Generate("loop", rand.New(rand.NewSource(1)), GenerateOptions{})
+	test.Error(t, err)
+}
+
+func FuzzGenerate(f *testing.F) {
+	var g Grammar
+	g.Add("list", `"(" item(s ",") ")"`).WS = Whitespaces
+	g.Add("item", `/\w+/`).WS = Whitespaces
+	g.Add("item", `list`)
+
+	corpus, err := g.Corpus("list", 10, rand.New(rand.NewSource(1)), GenerateOptions{MaxDepth: 5})
+	test.NoError(f, err)
+	for _, in := range corpus {
+		f.Add(in)
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		_, _, _ = g.Parse("list", in) // must not panic
+	})
+}
@@ -18,8 +18,23 @@ type Grammar struct {
 	// Trailing regexp
 	End *regexp.Regexp
 
+	// how many columns a tab counts for `<indent>` and `<dedent>` (default 8)
+	TabWidth int
+
+	// how deep the rules can nest (internal ones, like repetitions, included), 0 for no limit
+	// deeper inputs fail with a Limit error, instead of growing the stack
+	MaxDepth int
+
+	// if set, the input is split into tokens before parsing
+	Lexer *Lexer
+
+	// if set, receives the events of each parse, see TextTracer, JSONTracer and SlogTracer
+	Tracer Tracer
+
 	alts map[string]*Alts
-	Log  func(f string, args ...any)
+
+	// Deprecated: human readable and coloured debug output, use Tracer instead
+	Log func(f string, args ...any)
 
 	Stats struct {
 		Productions  int
@@ -54,6 +69,11 @@ func (this *Grammar) Dump() string {
 	return strings.Join(list, "\n") + "\n"
 }
 
+// the names of the public rules, in the order they were added
+func (this *Grammar) Rules() []string {
+	return this.ruleNames()
+}
+
 var (
 	Whitespaces            = regexp.MustCompile(`^[\s\n\r]*`)
 	CommentsAndWhitespaces = regexp.MustCompile(`^(\s|//[^\n]*\n?)*`)
@@ -145,6 +165,10 @@ func (this *Grammar) Parse(prodName string, text []byte) (any, Stats, error) {
 // parse the given text using the named alternative
 // check for unparsed text
 func (this *Grammar) ParseFile(prodName, fileName string, text []byte) (any, Stats, error) {
+	return this.parseFile(prodName, fileName, text, nil, nil)
+}
+
+func (this *Grammar) parseFile(prodName, fileName string, text []byte, cst *[]*Node, memo *memo) (any, Stats, error) {
 	var s Stats
 	t0 := time.Now()
 	p := pos{
@@ -152,21 +176,34 @@ func (this *Grammar) ParseFile(prodName, fileName string, text []byte) (any, Sta
 		file:  fileName,
 		src:   &Src{bytes: text},
 		stats: &s,
+		cst:   cst,
+		memo:  memo,
 	}
 	alt := this.alts[prodName]
-	if alt == nil {
-		return nil, s, ctx.NewErrorf(nil, "no prod named %q", prodName)
+	if alt == nil || len(alt.prods) == 0 { // Alt() creates it empty
+		err := p.NewErrorf("no prod named %q", prodName)
+		err.Kind, err.Rule = UnknownRule, prodName
+		return nil, s, err
+	}
+	if err := p.tokenize(); err != nil {
+		return nil, s, err.finish(s)
 	}
 	out, err := p.consumeProds(alt.prods...)
 	if err != nil {
-		return out, s, err
+		return out, s, err.finish(s)
 	}
 
 	if this.End != nil {
 		p.IgnoreRE(this.End, false)
 	}
+	if p.g.Lexer != nil {
+		p.skipToToken()
+	}
 	if p.Rem(10) != "" {
-		return out, s, ctx.NewErrorf(nil, "unparsed: %q", p.Rem(80))
+		p.expect(action{builtin: "<eof>"})
+		err := p.NewErrorf("unparsed: %q", p.Rem(80))
+		err.Kind = Unparsed
+		return out, s, err.finish(s)
 	}
 
 	dt := time.Since(t0)
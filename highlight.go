@@ -0,0 +1,173 @@
+package parse
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode/utf16"
+)
+
+// a classified part of the text, see Grammar.Highlight()
+type Span struct {
+	From, End int
+	Class     string
+}
+
+// a text and its classified spans, in order and not overlapping, the text in between has no class
+type Highlighted struct {
+	Text  []byte
+	Spans []Span
+}
+
+// the ANSI colours used if no theme is given to Highlighted.ANSI()
+var ANSITheme = map[string]string{
+	"keyword":  "1;34",
+	"string":   "32",
+	"number":   "36",
+	"comment":  "90",
+	"operator": "33",
+	"type":     "35",
+	"function": "1",
+	"regexp":   "31",
+}
+
+// parse the text and classify each terminal with its own class (see Prod.TermClass()) or the class of the innermost
+// production which has one (see Prod.Class()), the text skipped between terminals which is not blank (usually comments) is classified as "comment"
+func (this *Grammar) Highlight(start string, text []byte) (Highlighted, error) {
+	out := Highlighted{Text: text}
+	n, _, err := this.ParseCST(start, "", text)
+	if err != nil {
+		return out, err
+	}
+	trivia := func(list []Trivia) {
+		for _, t := range list {
+			// each line, trimmed
+			from := t.Pos.From
+			for _, line := range strings.SplitAfter(t.Text, "\n") {
+				trim := strings.TrimLeft(line, " \t\r\n")
+				lo := from + len(line) - len(trim)
+				trim = strings.TrimRight(trim, " \t\r\n")
+				if trim != "" {
+					out.Spans = append(out.Spans, Span{lo, lo + len(trim), "comment"})
+				}
+				from += len(line)
+			}
+		}
+	}
+	var walk func(n *Node, class string)
+	walk = func(n *Node, class string) {
+		if n.Class != "" {
+			class = n.Class
+		}
+		if len(n.Children) == 0 {
+			trivia(n.Leading)
+			if n.Rule == "" && class != "" && n.Pos.From < n.Pos.End {
+				out.Spans = append(out.Spans, Span{n.Pos.From, n.Pos.End, class})
+			}
+			trivia(n.Trailing)
+			return
+		}
+		for _, c := range n.Children {
+			walk(c, class)
+		}
+	}
+	walk(n, "")
+	return out, nil
+}
+
+// the text with the spans coloured using the given theme (class => SGR parameters, like "1;34"), or ANSITheme
+func (this Highlighted) ANSI(theme map[string]string) string {
+	if theme == nil {
+		theme = ANSITheme
+	}
+	var b strings.Builder
+	this.each(func(text []byte, class string) {
+		if code := theme[class]; code != "" {
+			b.WriteString("\033[" + code + "m")
+			b.Write(text)
+			b.WriteString("\033[0m")
+		} else {
+			b.Write(text)
+		}
+	})
+	return b.String()
+}
+
+// the escaped text with each span in a `<span class="...">`, to be used inside a `<pre>`
+func (this Highlighted) HTML() string {
+	var b strings.Builder
+	this.each(func(text []byte, class string) {
+		if class != "" {
+			b.WriteString(`<span class="` + html.EscapeString(class) + `">`)
+			b.WriteString(html.EscapeString(string(text)))
+			b.WriteString("</span>")
+		} else {
+			b.WriteString(html.EscapeString(string(text)))
+		}
+	})
+	return b.String()
+}
+
+// call f with each part of the text, and its class if it's a span
+func (this Highlighted) each(f func(text []byte, class string)) {
+	at := 0
+	for _, s := range this.Spans {
+		if s.From > at {
+			f(this.Text[at:s.From], "")
+		}
+		f(this.Text[s.From:s.End], s.Class)
+		at = s.End
+	}
+	if at < len(this.Text) {
+		f(this.Text[at:], "")
+	}
+}
+
+// the spans in the LSP semantic tokens encoding: 5 integers for each token, with the line and start (in UTF-16
+// units) relative to the previous token, the length, the index of the class in the legend and no modifiers
+// spans are split at the end of the lines, and the classes not in the legend are skipped
+func (this Highlighted) SemanticTokens(legend []string) []int {
+	types := map[string]int{}
+	for i, t := range legend {
+		types[t] = i
+	}
+	data := []int{}
+	line, lineAt := 0, 0 // the current line, and where it starts
+	prevLine, prevChar := 0, 0
+	at := 0
+	col := func(from, to int) int {
+		ct := 0
+		for _, r := range string(this.Text[from:to]) {
+			ct += utf16.RuneLen(r)
+		}
+		return ct
+	}
+	for _, s := range this.Spans {
+		typ, ok := types[s.Class]
+		if !ok {
+			continue
+		}
+		for from := s.From; from < s.End; {
+			// move to the line of from
+			for i := bytes.IndexByte(this.Text[at:from], '\n'); i >= 0; i = bytes.IndexByte(this.Text[at:from], '\n') {
+				at += i + 1
+				line++
+				lineAt = at
+			}
+			end := from + bytes.IndexByte(this.Text[from:s.End], '\n')
+			if end < from {
+				end = s.End
+			}
+			if end > from {
+				char := col(lineAt, from)
+				if line != prevLine {
+					prevChar = 0
+				}
+				data = append(data, line-prevLine, char-prevChar, col(from, end), typ, 0)
+				prevLine, prevChar = line, char
+			}
+			from = end + 1
+		}
+	}
+	return data
+}
@@ -0,0 +1,118 @@
+package parse_test
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func TestHighlight(t *testing.T) {
+	g, err := parse.LoadGrammar("let.prd", []byte(`%ws /(\s|#[^\n]*)*/
+%class kw keyword
+%class num number
+%class str string
+prog: stmt(s) <eof>
+stmt: kw name "=" value ";"
+kw: /let\b/
+name: /[a-z]+/
+value: num
+     | str
+num: /\d+/
+str: /"[^"]*"/
+`))
+	test.NoError(t, err)
+	g.Alt("name").Prods()[0].Class("variable")
+
+	text := "let x = 1; # one\nlet s = \"é\nb<\";\n"
+	h, err := g.Highlight("prog", []byte(text))
+	test.NoError(t, err)
+	test.EqualsGo(t, []parse.Span{
+		{0, 3, "keyword"},
+		{4, 5, "variable"},
+		{8, 9, "number"},
+		{11, 16, "comment"},
+		{17, 20, "keyword"},
+		{21, 22, "variable"},
+		{25, 32, "string"},
+	}, h.Spans)
+
+	test.EqualsGo(t, `<span class="keyword">let</span> <span class="variable">x</span> = <span class="number">1</span>; `+
+		`<span class="comment"># one</span>`+"\n"+
+		`<span class="keyword">let</span> <span class="variable">s</span> = <span class="string">&#34;é`+"\n"+`b&lt;&#34;</span>;`+"\n",
+		h.HTML())
+
+	test.EqualsGo(t, "\033[1mlet\033[0m x = 1; # one\n\033[1mlet\033[0m s = \"é\nb<\";\n", h.ANSI(map[string]string{"keyword": "1"}))
+	test.Contains(t, h.ANSI(nil), "\033[90m# one\033[0m")
+
+	// the string is split at the end of the line, and é is 1 UTF-16 unit
+	test.EqualsGo(t, []int{
+		0, 0, 3, 0, 0,
+		0, 4, 1, 1, 0,
+		0, 4, 1, 2, 0,
+		0, 3, 5, 4, 0,
+		1, 0, 3, 0, 0,
+		0, 4, 1, 1, 0,
+		0, 4, 2, 3, 0,
+		1, 0, 3, 3, 0,
+	}, h.SemanticTokens([]string{"keyword", "variable", "number", "string", "comment"}))
+	test.EqualsGo(t, []int{
+		0, 8, 1, 0, 0,
+	}, h.SemanticTokens([]string{"number"}))
+
+	_, err = g.Highlight("prog", []byte("let = 1;"))
+	test.Error(t, err)
+
+	// only comments
+	g, err = parse.LoadGrammar("opt.prd", []byte("%ws /(\\s|#[^\\n]*)*/\ndoc: [ /x/ ] <eof>\n"))
+	test.NoError(t, err)
+	h, err = g.Highlight("doc", []byte("# one\n  # two\n"))
+	test.NoError(t, err)
+	test.EqualsGo(t, []parse.Span{{0, 5, "comment"}, {8, 13, "comment"}}, h.Spans)
+
+	_, err = parse.LoadGrammar("bad.prd", []byte("%class nope keyword\nprog: /x/\n"))
+	test.Contains(t, err.Error(), `bad.prd:1: no rule named "nope"`)
+}
+
+func TestHighlightTerminals(t *testing.T) {
+	g, err := parse.LoadGrammar("if.prd", []byte(`%ws /\s*/
+%class stmt keyword "if"
+%class stmt keyword "else"
+%class cond operator /[<>]/
+%class cond number
+stmt: "if" cond "then" name [ "else" name ] <eof>
+cond: name /[<>]/ /\d+/
+name: /[a-z]+/
+`))
+	test.NoError(t, err)
+	h, err := g.Highlight("stmt", []byte("if a < 1 then b else c"))
+	test.NoError(t, err)
+	test.EqualsGo(t, []parse.Span{
+		{0, 2, "keyword"},
+		{3, 4, "number"}, // the class of the production, the terminals have their own
+		{5, 6, "operator"},
+		{7, 8, "number"},
+		{16, 20, "keyword"}, // in the optional group
+	}, h.Spans)
+
+	// the tokens of a lexer
+	g, err = parse.LoadGrammar("tok.prd", []byte(`%skip /\s*/
+%token NUM /\d+/
+%token ID /[a-z]+/
+%token OP /[+]/
+%class sum number NUM
+sum: NUM "+" ID
+`))
+	test.NoError(t, err)
+	h, err = g.Highlight("sum", []byte("1 + x"))
+	test.NoError(t, err)
+	test.EqualsGo(t, []parse.Span{{0, 1, "number"}}, h.Spans)
+
+	_, err = parse.LoadGrammar("bad.prd", []byte("%class prog keyword \"y\"\nprog: /x/\n"))
+	test.Contains(t, err.Error(), `bad.prd:1: no terminal "y" in rule prog`)
+
+	var g2 parse.Grammar
+	p := g2.Add("x", `"let" /\w+/`).TermClass(`"let"`, "keyword")
+	defer func() { test.Assert(t, recover() != nil) }()
+	p.TermClass(`"var"`, "keyword")
+}
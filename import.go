@@ -0,0 +1,220 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// the expressions of a foreign grammar (ABNF, PEG...), before being converted into directives
+type gnode struct {
+	kind     gkind
+	sub      []*gnode
+	text     string // rule name, literal or regexp
+	fold     bool   // case-insensitive literal
+	min, max int    // repetition, max < 0 means unbounded
+}
+
+type gkind int
+
+const (
+	gAlt gkind = iota // a / b
+	gSeq              // a b
+	gRep              // a*, a+, a?...
+	gRef              // rule name
+	gLit              // "a"
+	gRE               // [a-z]
+	gAnd              // &a
+	gNot              // !a
+)
+
+// simplify single-item alternations and sequences
+func gnodeOf(kind gkind, list []*gnode) *gnode {
+	if len(list) == 1 {
+		return list[0]
+	}
+	return &gnode{kind: kind, sub: list}
+}
+
+// adds the productions of a foreign grammar
+// each element of a sequence produces an item (lookaheads excluded), so actions can be attached later by rule name
+// nested groups become helper rules named `rule__gN`
+type importer struct {
+	g *Grammar
+}
+
+// run the import, if it fails remove what it added (rules, internal ones included, and productions)
+func (this *Grammar) importing(f func() error) error {
+	saved := map[string]int{}
+	for name, alt := range this.alts {
+		saved[name] = len(alt.prods)
+	}
+	stats := this.Stats
+	err := f()
+	if err != nil {
+		for name, alt := range this.alts {
+			if n, ok := saved[name]; ok {
+				alt.prods = alt.prods[:n:n]
+			} else {
+				delete(this.alts, name)
+			}
+		}
+		this.Stats.Productions, this.Stats.Alternations = stats.Productions, stats.Alternations
+	}
+	return err
+}
+
+// add the alternatives of a rule, each as a production
+func (this *importer) add(name, src string, n *gnode) error {
+	list := []*gnode{n}
+	if n.kind == gAlt {
+		list = n.sub
+	}
+	for _, alt := range list {
+		d, err := this.directive(name, src, alt)
+		if err != nil {
+			return err
+		}
+		if _, err := this.g.Alt(name).add(src, d); err != nil {
+			return ctx.NewErrorf(nil, "%s: rule %s `%s`: %v", src, name, d, err)
+		}
+	}
+	return nil
+}
+
+func (this *importer) directive(rule, src string, n *gnode) (string, error) {
+	if n.kind != gSeq {
+		return this.element(rule, src, n)
+	}
+	var list []string
+	for _, s := range n.sub {
+		d, err := this.element(rule, src, s)
+		if err != nil {
+			return "", err
+		}
+		list = append(list, d)
+	}
+	return strings.Join(list, " "), nil
+}
+
+func (this *importer) element(rule, src string, n *gnode) (string, error) {
+	switch n.kind {
+	case gRef:
+		return n.text, nil
+	case gLit:
+		re := regexp.QuoteMeta(n.text)
+		if n.fold && strings.ToLower(n.text) != strings.ToUpper(n.text) {
+			re = "(?i)" + re
+		}
+		return "/" + reDelim(re) + "/", nil
+	case gRE:
+		return "/" + reDelim(n.text) + "/", nil
+	case gAnd, gNot:
+		d, err := this.atom(rule, src, n.sub[0])
+		if err != nil {
+			return "", err
+		}
+		if n.kind == gAnd {
+			return "~&" + d, nil
+		}
+		return "~!" + d, nil
+	case gSeq:
+		if len(n.sub) == 0 {
+			return "//", nil // matches the empty string, and still produces an item
+		}
+		return this.helper(rule, src, n)
+	case gAlt:
+		return this.helper(rule, src, n)
+	case gRep:
+		if n.min == 0 && n.max == 1 {
+			d, err := this.directive(rule, src, n.sub[0])
+			if err != nil {
+				return "", err
+			}
+			return "[ " + d + " ]", nil
+		}
+		if n.max >= 0 && n.max < n.min {
+			return "", ctx.NewErrorf(nil, "%s: rule %s: invalid repetition %d*%d", src, rule, n.min, n.max)
+		}
+		atom, err := this.atom(rule, src, n.sub[0])
+		if err != nil {
+			return "", err
+		}
+		var list []string
+		for i := 0; i < n.min; i++ {
+			list = append(list, atom)
+		}
+		if n.max < 0 {
+			name := atom
+			if k := n.sub[0].kind; k == gLit || k == gRE { // `x(s)` needs a rule name
+				name, err = this.helper(rule, src, n.sub[0])
+				if err != nil {
+					return "", err
+				}
+			}
+			if n.min > 0 {
+				list[len(list)-1] = name + "(s)"
+			} else {
+				list = append(list, "[ "+name+"(s) ]")
+			}
+		} else if n.max > n.min {
+			opt := ""
+			for i := n.min; i < n.max; i++ {
+				opt = strings.TrimSpace("[ "+atom+" "+opt) + " ]"
+			}
+			list = append(list, opt)
+		}
+		if len(list) == 0 {
+			return "//", nil
+		}
+		return strings.Join(list, " "), nil
+	}
+	return "", ctx.NewErrorf(nil, "%s: rule %s: unexpected %v", src, rule, n.kind)
+}
+
+// a single directive: terminals and references are inlined, the rest goes in a helper rule
+func (this *importer) atom(rule, src string, n *gnode) (string, error) {
+	switch n.kind {
+	case gRef, gLit, gRE:
+		return this.element(rule, src, n)
+	}
+	return this.helper(rule, src, n)
+}
+
+func (this *importer) helper(rule, src string, n *gnode) (string, error) {
+	name := fmt.Sprintf("%s__g%d", rule, this.g.repCt.Add(1))
+	return name, this.add(name, src, n)
+}
+
+// escape the `/` so the regexp can be used in a directive
+func reDelim(re string) string {
+	var b strings.Builder
+	for i := 0; i < len(re); i++ {
+		switch re[i] {
+		case '\\':
+			b.WriteByte(re[i])
+			if i+1 < len(re) {
+				i++
+				b.WriteByte(re[i])
+			}
+		case '/':
+			b.WriteString(`\/`)
+		default:
+			b.WriteByte(re[i])
+		}
+	}
+	return b.String()
+}
+
+// replace anything but letters, digits and `_`, so it can be used as a rule name
+func ruleName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,294 @@
+package parse
+
+import (
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// the result of a parse which can be updated after the text is edited, reusing the results of the rules
+// which didn't look at the edited text, like an editor would do on each keystroke:
+//
+//	tree, err := g.ParseTree("doc", "file.x", text)
+//	...
+//	tree.Edit(10, 12, 15) // text[10:12] was replaced by 5 bytes
+//	out, stats, err := tree.Reparse(newText)
+//
+// the results are kept as they are, so the Return() functions must not modify their arguments
+// rules which use a Pos (or contain rules which do) are only reused if they are before the edit,
+// and grammars with a Lexer are always parsed again
+type Tree struct {
+	g     *Grammar
+	start string
+	file  string
+	text  []byte
+	out   any
+	memo  *memo
+}
+
+// parse the text like ParseFile, keeping what's needed to reparse it later
+// an error is returned if the text can't be parsed, but the Tree can still be edited and reparsed
+func (this *Grammar) ParseTree(prodName, fileName string, text []byte) (*Tree, error) {
+	t := &Tree{
+		g:     this,
+		start: prodName,
+		file:  fileName,
+		memo:  &memo{table: map[memoKey]*memoEntry{}},
+	}
+	_, _, err := t.Reparse(text)
+	return t, err
+}
+
+// the result of the last parse
+func (this *Tree) Value() any { return this.out }
+
+// the text of the last parse
+func (this *Tree) Text() []byte { return this.text }
+
+// the bytes from start to oldEnd have been replaced, and now end at newEnd
+// call it for each change, in order, then Reparse() the new text
+func (this *Tree) Edit(start, oldEnd, newEnd int) {
+	delta := newEnd - oldEnd
+	dependent := this.g.posRules()
+	table := map[memoKey]*memoEntry{}
+	for k, e := range this.memo.table {
+		switch {
+		case max(e.hi, e.errHi) <= start:
+			table[k] = e
+		case e.lo >= oldEnd && !dependent[k.rule]:
+			s := *e
+			s.end += delta
+			s.lo += delta
+			s.hi += delta
+			s.errHi += delta
+			if s.farthest >= 0 {
+				s.farthest += delta
+			}
+			if e.err != nil {
+				err := *e.err
+				err.Pos.End += delta
+				err.Pos.From += delta
+				s.err = &err
+			}
+			k.at += delta
+			table[k] = &s
+		}
+	}
+	this.memo.table = table
+}
+
+// parse the new text, reusing what wasn't affected by the edits
+func (this *Tree) Reparse(text []byte) (any, Stats, error) {
+	this.text = text
+	m := this.memo
+	if this.g.Lexer != nil {
+		m = nil
+	} else {
+		m.used = map[memoKey]bool{}
+	}
+	out, stats, err := this.g.parseFile(this.start, this.file, text, nil, m)
+	this.out = out
+	if m != nil {
+		// forget what wasn't needed
+		for k := range m.table {
+			if !m.used[k] {
+				delete(m.table, k)
+			}
+		}
+	}
+	return out, stats, err
+}
+
+type memoKey struct {
+	rule     string
+	at       int
+	inverted bool // inside a negative lookahead nothing is expected, see pos.inverted
+}
+
+type memoEntry struct {
+	out        any
+	err        *Error
+	end        int
+	lo, hi     int        // the text looked at, hi excluded
+	errHi      int        // the error message quotes the text up to here
+	callers    *ruleFrame // the rules being parsed, see Error.rules
+	farthest   int        // what was expected, see Stats.Expected, -1 if nothing
+	expected   []string
+	inIndents  []int // the indentation stack before, and after
+	outIndents []int
+}
+
+type memo struct {
+	table  map[memoKey]*memoEntry
+	used   map[memoKey]bool
+	lo, hi int // the text looked at by the rule being parsed
+}
+
+func (this *memo) look(lo, hi int) {
+	this.lo = min(this.lo, lo)
+	this.hi = max(this.hi, hi)
+}
+
+// consume the rule, or reuse its previous result
+func (this *memo) consume(p *pos, prods []*Prod) (any, *Error) {
+	k := memoKey{prods[0].Name, p.at, p.inverted}
+	if e := this.table[k]; e != nil && slices.Equal(e.inIndents, p.indents) {
+		this.used[k] = true
+		this.look(e.lo, e.hi)
+		p.stats.Reused++
+		p.at = e.end
+		p.indents = e.outIndents
+		e.expect(p.stats)
+		if e.err == nil {
+			return e.out, nil
+		}
+		// the error is in the new text, and the rule might have been called by other rules
+		err := *e.err
+		err.Pos.File, err.Pos.Src = p.file, p.src
+		err.rules = rebase(err.rules, e.callers, p.rules)
+		return e.out, &err
+	}
+
+	lo, hi := this.lo, this.hi
+	this.lo, this.hi = p.at, p.at
+	farthest, expected := p.stats.Farthest, p.stats.Expected
+	p.stats.Farthest, p.stats.Expected = -1, nil
+	in := p.indents
+	out, err := p.runProds(prods)
+	e := &memoEntry{
+		out:        out,
+		err:        err,
+		end:        p.at,
+		lo:         this.lo,
+		hi:         this.hi,
+		inIndents:  in,
+		outIndents: p.indents,
+		farthest:   p.stats.Farthest,
+		expected:   p.stats.Expected,
+	}
+	if err != nil {
+		e.callers = p.rules
+	}
+	p.stats.Farthest, p.stats.Expected = farthest, expected
+	e.expect(p.stats)
+	if err != nil {
+		// the message has the text after the error, see Rem(80)
+		e.errHi = err.Pos.End + 80
+		if e.errHi > len(p.src.bytes) {
+			e.errHi = len(p.src.bytes) + 1
+		}
+	}
+	if err == nil || err.Kind != Limit { // the depth depends on the callers
+		this.table[k] = e
+		this.used[k] = true
+	}
+	this.lo, this.hi = lo, hi
+	this.look(e.lo, e.hi)
+	return out, err
+}
+
+// the frames down to callers, on top of the new callers
+func rebase(f, callers, to *ruleFrame) *ruleFrame {
+	if f == callers || f == nil {
+		return to
+	}
+	return &ruleFrame{f.name, rebase(f.parent, callers, to)}
+}
+
+// add what the rule expected to the stats, as if it was parsed again
+func (this *memoEntry) expect(s *Stats) {
+	for _, what := range this.expected {
+		s.expect(this.farthest, what)
+	}
+}
+
+// record how far the regexp looks at the text from the current position
+func (this *pos) lookRE(re *regexp.Regexp) {
+	if this.memo == nil {
+		return
+	}
+	r := &countReader{b: this.src.bytes[this.at:]}
+	re.FindReaderIndex(r)
+	hi := this.at + r.read
+	if r.eof {
+		hi = len(this.src.bytes) + 1 // the end of the text was checked
+	}
+	this.memo.look(this.at, hi)
+}
+
+// record that a builtin looks at the lines around the offset: from the end of the previous line, to the end
+// of the first non blank line, which is what `<indent>` might skip
+func (this *pos) lookLines(at int) {
+	if this.memo == nil {
+		return
+	}
+	src := this.src.bytes
+	lo := at
+	for lo > 0 && src[lo-1] != '\n' {
+		lo--
+	}
+	hi := at
+	blank := true
+	for hi < len(src) {
+		c := src[hi]
+		hi++
+		if c == '\n' {
+			if !blank {
+				break
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\r' {
+			blank = false
+		}
+	}
+	if hi == len(src) {
+		hi++ // includes the end of the text
+	}
+	this.memo.look(max(lo-1, 0), hi)
+}
+
+// a RuneReader which records how far it was read
+type countReader struct {
+	b    []byte
+	read int
+	eof  bool
+}
+
+func (this *countReader) ReadRune() (rune, int, error) {
+	if this.read >= len(this.b) {
+		this.eof = true
+		return 0, 0, io.EOF
+	}
+	r, n := utf8.DecodeRune(this.b[this.read:])
+	this.read += n
+	return r, n, nil
+}
+
+// the rules which produce a Pos, or contain rules which do, and can't be moved
+func (this *Grammar) posRules() map[string]bool {
+	out := map[string]bool{}
+	pos := reflect.TypeOf(Pos{})
+	for changed := true; changed; {
+		changed = false
+		for name, alt := range this.alts {
+			if out[name] {
+				continue
+			}
+			for _, p := range alt.prods {
+				dep := p.retSig != nil && p.retSig.NumIn() > 0 && p.retSig.In(0) == pos
+				for _, act := range p.actions {
+					dep = dep || (act.prod != "" && out[act.prod])
+				}
+				if dep {
+					out[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+// with pos, `@` is a value which returns its offset
+func listGrammar(t *testing.T, pos bool) *Grammar {
+	g := &Grammar{}
+	ws := regexp.MustCompile(`^\s*`)
+	g.Add("doc", `item(s) <eof>`).WS = ws
+	g.Add("item", `key "=" value ";"`).Return(func(k string, v any) string {
+		return fmt.Sprintf("%s=%v", k, v)
+	}).WS = ws
+	g.Add("key", `/[a-z]+/`).WS = ws
+	g.Add("value", `"[" value(s ",") "]"`).WS = ws
+	g.Add("value", `/\d+/`).WS = ws
+	if pos {
+		g.Add("value", `at`).WS = ws
+		g.Add("at", `"@"`).Return(func(p Pos) int { return p.From }).WS = ws
+	}
+	test.NoError(t, g.Verify())
+	return g
+}
+
+// the same rules are parsed inside and outside a negative lookahead
+func notGrammar(t *testing.T) *Grammar {
+	g := &Grammar{}
+	ws := regexp.MustCompile(`^\s*`)
+	g.Add("doc", `item(s) <eof>`).WS = ws
+	g.Add("item", `!kw word ";"`).WS = ws
+	g.Add("item", `kw`).WS = ws
+	g.Add("kw", `/[a-z]+/ "!"`).WS = ws
+	g.Add("word", `/[a-z]+/`).WS = ws
+	test.NoError(t, g.Verify())
+	return g
+}
+
+func TestTree(t *testing.T) {
+	g := listGrammar(t, false)
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("k%c = [%d, [%d, 7]];", 'a'+i%26, i, i*2))
+	}
+	text := strings.Join(lines, "\n")
+	tree, err := g.ParseTree("doc", "", []byte(text))
+	test.NoError(t, err)
+	_, full, _ := g.Parse("doc", []byte(text))
+
+	// change a number in the middle: `ky = [50, [100, 7]]`
+	at := strings.Index(text, "[100,") + 1
+	text2 := text[:at] + "4242" + text[at+3:]
+	tree.Edit(at, at+3, at+4)
+	out, s, err := tree.Reparse([]byte(text2))
+	test.NoError(t, err)
+	t.Logf("full: %d alternations, reparse: %d alternations, %d reused", full.Alternations, s.Alternations, s.Reused)
+	test.Assert(t, s.Alternations*20 < full.Alternations)
+	test.Assert(t, s.Reused > 0)
+
+	exp, _, err := g.Parse("doc", []byte(text2))
+	test.NoError(t, err)
+	test.EqualsGo(t, exp, out)
+	test.EqualsGo(t, exp, tree.Value())
+	test.Contains(t, out.([]any)[50].(string), "[50 [4242 7]]")
+}
+
+// the rules which use a Pos are parsed again if they are after the edit
+func TestTreePos(t *testing.T) {
+	g := listGrammar(t, true)
+	text := "a = 1; b = [@, 2];"
+	tree, err := g.ParseTree("doc", "", []byte(text))
+	test.NoError(t, err)
+	test.EqualsGo(t, []any{"a=1", "b=[12 2]"}, tree.Value())
+
+	tree.Edit(4, 5, 7) // 1 => 100
+	out, s, err := tree.Reparse([]byte("a = 100; b = [@, 2];"))
+	test.NoError(t, err)
+	test.EqualsGo(t, []any{"a=100", "b=[14 2]"}, out)
+	test.Assert(t, s.Reused > 0) // the key `a`
+}
+
+// random edits must give the same results as parsing from scratch
+func TestTreeEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, c := range []struct {
+		g     *Grammar
+		start string
+		text  string
+		alpha string
+	}{
+		{listGrammar(t, true), "doc", "a=1; b = [2, [3,@]];\nc=[4];", "ab1@[],;= \n"},
+		{indentGrammar(t), "file", "a:\n  b\n  c:\n    d\ne\n", "ab: \n\t"},
+		{notGrammar(t), "doc", "a; b! c;", "ab!;? "},
+	} {
+		text := c.text
+		tree, _ := c.g.ParseTree(c.start, "", []byte(text))
+		for i := 0; i < 500; i++ {
+			from := rnd.Intn(len(text) + 1)
+			end := min(len(text), from+rnd.Intn(3))
+			ins := ""
+			for j := rnd.Intn(3); j > 0; j-- {
+				ins += string(c.alpha[rnd.Intn(len(c.alpha))])
+			}
+			text = text[:from] + ins + text[end:]
+			if len(text) > 80 || len(text) == 0 {
+				text = c.text
+				tree, _ = c.g.ParseTree(c.start, "", []byte(text))
+				continue
+			}
+			tree.Edit(from, end, from+len(ins))
+			got, gotStats, gotErr := tree.Reparse([]byte(text))
+			exp, expStats, expErr := c.g.Parse(c.start, []byte(text))
+			if gotStats.Farthest != expStats.Farthest || fmt.Sprint(gotStats.Expected) != fmt.Sprint(expStats.Expected) {
+				t.Fatalf("%q: expected %v at %d, got %v at %d", text,
+					expStats.Expected, expStats.Farthest, gotStats.Expected, gotStats.Farthest)
+			}
+			if (gotErr == nil) != (expErr == nil) {
+				t.Fatalf("%q: expected error %v, got %v", text, expErr, gotErr)
+			}
+			if gotErr != nil {
+				test.EqualsGo(t, fmt.Sprintf("%+v", expErr), fmt.Sprintf("%+v", gotErr))
+				continue
+			}
+			expJSON, _ := json.Marshal(exp)
+			gotJSON, _ := json.Marshal(got)
+			if string(expJSON) != string(gotJSON) {
+				t.Fatalf("%q: expected %s, got %s", text, expJSON, gotJSON)
+			}
+		}
+	}
+}
+
+// an error of a rule which was moved is reported in the new text
+func TestTreeError(t *testing.T) {
+	g := &Grammar{}
+	ws := regexp.MustCompile(`^\s*`)
+	g.Add("doc", `item(s) <eof>`).WS = ws
+	g.Add("item", `key + "=" value ";"`).WS = ws
+	g.Add("key", `/[a-z]+/`).WS = ws
+	g.Add("value", `/\d+/`).WS = ws
+
+	tree, err := g.ParseTree("doc", "f.x", []byte("a = 1;\nb = x;"))
+	test.Error(t, err)
+	tree.Edit(4, 5, 7)
+	text := []byte("a = 100;\nb = x;")
+	_, s, err := tree.Reparse(text)
+	test.Assert(t, s.Reused > 0)
+	_, _, exp := g.ParseFile("doc", "f.x", text)
+	test.EqualsGo(t, fmt.Sprintf("%+v", exp), fmt.Sprintf("%+v", err))
+	test.Contains(t, fmt.Sprintf("%+v", err), "f.x:2:5: ")
+}
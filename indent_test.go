@@ -0,0 +1,72 @@
+package parse
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+type indentNode struct {
+	Name  string        `json:"name"`
+	Block []*indentNode `json:"block,omitempty"`
+}
+
+func indentGrammar(t *testing.T) *Grammar {
+	g := &Grammar{}
+	if testing.Verbose() {
+		g.Log = t.Logf
+	}
+	ws := regexp.MustCompile(`^[ \t]*`)
+	g.Add("file", `stmt(s) <eof>`).WS = ws
+	g.Add("stmt", `<samedent> name ":" ~/\n/ block`).Return(func(name string, block []*indentNode) *indentNode {
+		return &indentNode{name, block}
+	}).WS = ws
+	g.Add("stmt", `<samedent> name ~/(\n|$)/`).Return(func(name string) *indentNode {
+		return &indentNode{Name: name}
+	}).WS = ws
+	g.Add("block", `<indent> stmt(s) <dedent>`).WS = ws
+	g.Add("name", `/\w+/`).WS = ws
+	test.NoError(t, g.Verify())
+	return g
+}
+
+func TestIndent(t *testing.T) {
+	g := indentGrammar(t)
+	out, _, err := g.Parse("file", []byte(""+
+		"a:\n"+
+		"  b\n"+
+		"\n"+
+		"  c:\n"+
+		"\td\n"+
+		"    \n"+
+		"e:\n"+
+		"    f\n"+
+		"g\n"))
+	test.NoError(t, err)
+	test.EqualsJSON(t, `[`+
+		`{"name":"a","block":[{"name":"b"},{"name":"c","block":[{"name":"d"}]}]},`+
+		`{"name":"e","block":[{"name":"f"}]},`+
+		`{"name":"g"}`+
+		`]`, out)
+}
+
+func TestIndentBacktrack(t *testing.T) {
+	g := indentGrammar(t)
+	{
+		// block closed by the end of input
+		out, _, err := g.Parse("file", []byte("a:\n  b:\n    c"))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `[{"name":"a","block":[{"name":"b","block":[{"name":"c"}]}]}]`, out)
+	}
+	{
+		// inconsistent dedent
+		_, _, err := g.Parse("file", []byte("a:\n    b\n  c\n"))
+		test.Error(t, err)
+	}
+	{
+		// missing block
+		_, _, err := g.Parse("file", []byte("a:\nb\n"))
+		test.Error(t, err)
+	}
+}
@@ -0,0 +1,152 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// a piece of text recognized by a Lexer
+type Token struct {
+	Kind string
+	Text string
+	Pos  Pos
+}
+
+// optional stage which splits the input into tokens before parsing
+// when set on a Grammar, directives can refer to token kinds by name (e.g. `IDENT`), and
+// terminals (`"select"` or `/\d+/`) must match a whole token
+type Lexer struct {
+	// what to ignore between tokens (whitespaces, comments...)
+	Skip *regexp.Regexp
+
+	rules []lexRule
+	kinds map[string]bool
+}
+
+type lexRule struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// add a new token kind, matched by the given regexp
+// the longest match wins, and ties go to the kind added first
+// panics if the regexp is invalid
+func (this *Lexer) Add(kind, re string) *Lexer {
+	if this.kinds == nil {
+		this.kinds = map[string]bool{}
+	}
+	this.kinds[kind] = true
+	this.rules = append(this.rules, lexRule{
+		kind: kind,
+		re:   regexp.MustCompile(`^(?:` + re + `)`),
+	})
+	return this
+}
+
+// true if the given name is a token kind
+func (this *Lexer) Has(kind string) bool {
+	return this != nil && this.kinds[kind]
+}
+
+// split the input into tokens
+func (this *Lexer) Tokenize(fileName string, in []byte) ([]Token, error) {
+	toks, err := this.tokenize(fileName, &Src{bytes: in})
+	if err != nil {
+		return toks, err
+	}
+	return toks, nil
+}
+
+func (this *Lexer) tokenize(fileName string, src *Src) ([]Token, *Error) {
+	var toks []Token
+	at := 0
+	for {
+		if this.Skip != nil {
+			at += len(this.Skip.Find(src.bytes[at:]))
+		}
+		if at == len(src.bytes) {
+			return toks, nil
+		}
+		best := -1
+		bestLen := 0
+		for i, r := range this.rules {
+			m := r.re.Find(src.bytes[at:])
+			if m != nil && len(m) > bestLen {
+				best = i
+				bestLen = len(m)
+			}
+		}
+		if best < 0 {
+			rem := src.bytes[at:]
+			if len(rem) > 10 {
+				rem = rem[0:10]
+			}
+			return toks, &Error{Pos: Pos{From: at, End: at, File: fileName, Src: src}, Err: fmt.Errorf("invalid token %q", rem)}
+		}
+		toks = append(toks, Token{
+			Kind: this.rules[best].kind,
+			Text: string(src.bytes[at : at+bestLen]),
+			Pos:  Pos{at, at + bestLen, fileName, src},
+		})
+		at += bestLen
+	}
+}
+
+// the first token at or after the current position, or nil
+func (this *pos) nextToken() *Token {
+	i := sort.Search(len(this.toks), func(i int) bool {
+		return this.toks[i].Pos.From >= this.at
+	})
+	if i == len(this.toks) {
+		return nil
+	}
+	return &this.toks[i]
+}
+
+// move to the next token, or to the end if there are none left
+func (this *pos) skipToToken() {
+	if tok := this.nextToken(); tok != nil {
+		this.at = tok.Pos.From
+	} else {
+		this.at = len(this.src.bytes)
+	}
+}
+
+// consume the next token if it's of the given kind
+func (this *pos) ConsumeKind(kind string, negative bool) (string, *Error) {
+	this.skipToToken()
+	tok := this.nextToken()
+	return this.consumeToken(tok, tok != nil && tok.Kind == kind, kind, negative)
+}
+
+// consume the next token if the whole text matches the given regexp
+func (this *pos) ConsumeTokenRE(re *regexp.Regexp, negative bool) (string, *Error) {
+	this.skipToToken()
+	tok := this.nextToken()
+	ok := false
+	if tok != nil {
+		m := re.FindStringIndex(tok.Text)
+		ok = m != nil && m[1] == len(tok.Text)
+	}
+	return this.consumeToken(tok, ok, "/"+re.String()+"/", negative)
+}
+
+func (this *pos) consumeToken(tok *Token, ok bool, what string, negative bool) (string, *Error) {
+	if !ok {
+		if negative {
+			this.Log("✅ NEG AHEAD %s", what)
+			return "", nil
+		}
+		this.Log("❌ FAIL %s", what)
+		return "", this.NewErrorf("expected %s got %q", what, this.Rem(80))
+	}
+	if negative {
+		this.Log("❌ NEG AHEAD %s %q", what, tok.Text)
+		return "", this.NewErrorf("unwanted %s", what)
+	}
+	this.cstLeaf(tok.Pos.From, tok.Pos.End)
+	this.at = tok.Pos.End
+	this.Log("✅ CONSUMED %s %q", what, tok.Text)
+	return tok.Text, nil
+}
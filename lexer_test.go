@@ -0,0 +1,74 @@
+package parse
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func sqlLexer() *Lexer {
+	l := &Lexer{
+		Skip: regexp.MustCompile(`^(\s|--[^\n]*)*`),
+	}
+	l.Add("IDENT", `[a-zA-Z_]\w*`)
+	l.Add("NUMBER", `\d+`)
+	l.Add("OP", `[,*=;]|<=|>=|<|>`)
+	return l
+}
+
+func TestTokenize(t *testing.T) {
+	toks, err := sqlLexer().Tokenize("", []byte("select a,b -- comment\nfrom t where x<=10"))
+	test.NoError(t, err)
+	var kinds, texts []string
+	for _, tok := range toks {
+		kinds = append(kinds, tok.Kind)
+		texts = append(texts, tok.Text)
+	}
+	test.EqualsJSON(t, `["IDENT","IDENT","OP","IDENT","IDENT","IDENT","IDENT","IDENT","OP","NUMBER"]`, kinds)
+	test.EqualsJSON(t, `["select","a",",","b","from","t","where","x","<=","10"]`, texts)
+	test.EqualsGo(t, 22, toks[4].Pos.From)
+
+	_, err = sqlLexer().Tokenize("", []byte("select $"))
+	test.Error(t, err)
+}
+
+func TestLexerGrammar(t *testing.T) {
+	g := Grammar{
+		Lexer: sqlLexer(),
+	}
+	if testing.Verbose() {
+		g.Log = t.Logf
+	}
+	g.Add("select", `"select" cols "from" IDENT [ "where" cond ]`).Return(func(cols []string, table string, cond any) []any {
+		return []any{cols, table, cond}
+	})
+	g.Add("cols", `"*"`).Return(func() []string { return nil })
+	g.Add("cols", `col(s ",")`)
+	g.Add("col", `!"from" IDENT`)
+	g.Add("cond", `IDENT OP value`)
+	g.Add("value", `NUMBER`)
+	g.Add("value", `IDENT`)
+	test.NoError(t, g.Verify())
+
+	{
+		out, _, err := g.Parse("select", []byte("select a, b\nfrom t -- all\n"))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `[["a","b"],"t",null]`, out)
+	}
+	{
+		out, _, err := g.Parse("select", []byte("select * from t where x <= 10"))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `[null,"t",["x","<=","10"]]`, out)
+	}
+	{
+		// literals must match a whole token
+		_, _, err := g.Parse("select", []byte("selectx a from t"))
+		test.Error(t, err)
+	}
+	{
+		_, _, err := g.Parse("select", []byte("select a from 12"))
+		test.Error(t, err)
+		test.Contains(t, err.Error(), "expected IDENT")
+	}
+}
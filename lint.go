@@ -0,0 +1,323 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a problem found by Grammar.Lint()
+type Lint struct {
+	Src       string // file:line where the production was added
+	Rule      string
+	Directive string
+	Msg       string
+}
+
+func (this Lint) String() string {
+	return fmt.Sprintf("%s: %s `%s`: %s", this.Src, this.Rule, this.Directive, this.Msg)
+}
+
+// check the grammar for common mistakes, beyond what Verify() does:
+// references to empty productions, shadowed alternatives, repetitions which would loop,
+// left recursions, and return functions which can't accept what the directive produces
+// if any start production is given, also reports the rules which can't be reached from them
+func (this *Grammar) Lint(start ...string) []Lint {
+	l := linter{
+		g:        this,
+		nullable: this.fixpoint(this.nullableAction),
+		always:   this.fixpoint(this.alwaysAction),
+	}
+	l.names = make([]string, 0, len(this.alts))
+	for name := range this.alts {
+		l.names = append(l.names, name)
+	}
+	sort.Strings(l.names)
+
+	l.references()
+	if len(start) > 0 {
+		l.unreachable(start)
+	}
+	l.shadowed()
+	l.loops()
+	l.leftRecursion()
+	l.argTypes()
+
+	sort.SliceStable(l.out, func(i, j int) bool {
+		return srcLess(l.out[i].Src, l.out[j].Src)
+	})
+	return l.out
+}
+
+type linter struct {
+	g        *Grammar
+	names    []string
+	nullable map[string]bool
+	always   map[string]bool
+	out      []Lint
+}
+
+func (this *linter) add(p *Prod, f string, args ...any) {
+	for p.wsFrom != nil && strings.Contains(p.Name, ",") {
+		p = p.wsFrom // report internal productions on their parent
+	}
+	this.out = append(this.out, Lint{
+		Src:       p.src,
+		Rule:      p.Name,
+		Directive: p.Directive,
+		Msg:       fmt.Sprintf(f, args...),
+	})
+}
+
+// the name refers to a token kind, not a production
+func (this *Grammar) isKind(name string) bool {
+	alt := this.alts[name]
+	return (alt == nil || len(alt.prods) == 0) && this.Lexer.Has(name)
+}
+
+// compute a property for each alternation, true if any production has all the actions with the property
+func (this *Grammar) fixpoint(f func(act action, known map[string]bool) bool) map[string]bool {
+	known := map[string]bool{}
+	for changed := true; changed; {
+		changed = false
+		for name, alt := range this.alts {
+			if known[name] {
+				continue
+			}
+			for _, p := range alt.prods {
+				all := true
+				for _, act := range p.actions {
+					if !f(act, known) {
+						all = false
+						break
+					}
+				}
+				if all {
+					known[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return known
+}
+
+// the action can succeed without consuming any text
+func (this *Grammar) nullableAction(act action, nullable map[string]bool) bool {
+	switch {
+	case act.message != "" && !act.negative:
+		return false // `<error:...>` never succeeds
+	case act.commit, act.negative, act.ahead, act.builtin != "":
+		return true
+	case act.re != nil:
+		return this.Lexer == nil && act.re.MatchString("")
+	case act.prod != "":
+		return nullable[act.prod]
+	}
+	return true
+}
+
+// the action succeeds whatever the input is
+func (this *Grammar) alwaysAction(act action, always map[string]bool) bool {
+	switch {
+	case act.commit:
+		return true
+	case act.negative, act.ahead, act.builtin != "":
+		return false
+	case act.re != nil:
+		if this.Lexer != nil {
+			return false
+		}
+		for _, s := range []string{"", "x", "0", " ", "\n", "\x00"} {
+			if !act.re.MatchString(s) {
+				return false
+			}
+		}
+		return true
+	case act.prod != "":
+		return always[act.prod]
+	}
+	return true
+}
+
+func (this *linter) references() {
+	for _, name := range this.names {
+		for _, p := range this.g.alts[name].prods {
+			for _, act := range p.actions {
+				if act.prod == "" || this.g.isKind(act.prod) {
+					continue
+				}
+				if alt := this.g.alts[act.prod]; alt == nil || len(alt.prods) == 0 {
+					this.add(p, "refers to empty %q", act.prod)
+				}
+			}
+		}
+	}
+}
+
+func (this *linter) unreachable(start []string) {
+	seen := map[string]bool{}
+	todo := append([]string{}, start...)
+	for len(todo) > 0 {
+		name := todo[len(todo)-1]
+		todo = todo[0 : len(todo)-1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if alt := this.g.alts[name]; alt != nil {
+			for _, p := range alt.prods {
+				for _, act := range p.actions {
+					if act.prod != "" {
+						todo = append(todo, act.prod)
+					}
+				}
+			}
+		}
+	}
+	for _, name := range this.names {
+		alt := this.g.alts[name]
+		if seen[name] || alt.internal != "" || len(alt.prods) == 0 {
+			continue
+		}
+		this.add(alt.prods[0], "unreachable from %s", strings.Join(start, ", "))
+	}
+}
+
+func (this *linter) shadowed() {
+	for _, name := range this.names {
+		alt := this.g.alts[name]
+		if alt.internal != "" {
+			continue
+		}
+		var by *Prod
+		for _, p := range alt.prods {
+			if by != nil {
+				this.add(p, "shadowed by `%s` at %s, which always matches", by.Directive, by.src)
+				continue
+			}
+			always := !p.retErr
+			for _, act := range p.actions {
+				always = always && this.g.alwaysAction(act, this.always)
+			}
+			if always {
+				by = p
+			}
+		}
+	}
+}
+
+func (this *linter) loops() {
+	for _, name := range this.names {
+		alt := this.g.alts[name]
+		if alt.internal != "rep_" {
+			continue
+		}
+		p := alt.prods[0] // `sep item rep_`, or `item rep_`
+		nullable := true
+		for _, act := range p.actions[0 : len(p.actions)-1] {
+			nullable = nullable && this.g.nullableAction(act, this.nullable)
+		}
+		if nullable {
+			item := p.actions[len(p.actions)-2]
+			this.add(p, "repetition of `%s` can match empty text, and would loop", item)
+		}
+	}
+}
+
+func (this *linter) leftRecursion() {
+	// alternations which can be called without consuming any text
+	edges := map[string][]string{}
+	for _, name := range this.names {
+		for _, p := range this.g.alts[name].prods {
+			for _, act := range p.actions {
+				if act.prod != "" && !this.g.isKind(act.prod) {
+					edges[name] = append(edges[name], act.prod)
+				}
+				if !this.g.nullableAction(act, this.nullable) {
+					break
+				}
+			}
+		}
+	}
+
+	// find the cycles, reporting each of them once
+	done := map[string]bool{}
+	var path []string
+	onPath := map[string]int{}
+	var visit func(name string)
+	visit = func(name string) {
+		if i, ok := onPath[name]; ok {
+			if i == len(path)-1 && this.g.alts[name].internal == "rep_" {
+				return // reported by loops()
+			}
+			cycle := append(append([]string{}, path[i:]...), name)
+			first := this.g.alts[path[i]].prods[0]
+			this.add(first, "left recursion: %s", strings.Join(cycle, " → "))
+			return
+		}
+		if done[name] {
+			return
+		}
+		onPath[name] = len(path)
+		path = append(path, name)
+		for _, next := range edges[name] {
+			visit(next)
+		}
+		path = path[0 : len(path)-1]
+		delete(onPath, name)
+		done[name] = true
+	}
+	for _, name := range this.names {
+		visit(name)
+	}
+}
+
+func (this *linter) argTypes() {
+	str := reflect.TypeOf("")
+	for _, name := range this.names {
+		for _, p := range this.g.alts[name].prods {
+			for _, act := range p.actions {
+				if act.argType == nil {
+					continue
+				}
+				switch {
+				case act.re != nil, this.g.isKind(act.prod):
+					if !str.ConvertibleTo(act.argType) {
+						this.add(p, "`%s` returns a string, but the return function expects %v", act, act.argType)
+					}
+				case act.prod != "":
+					alt := this.g.alts[act.prod]
+					if alt == nil {
+						continue
+					}
+					for _, sub := range alt.prods {
+						if sub.retType != nil {
+							continue // checked by Verify()
+						}
+						n := sub.items()
+						if n > 1 && act.argType.Kind() != reflect.Slice && act.argType.Kind() != reflect.Interface {
+							this.add(p, "`%s` at %s returns a list of %d items, but the return function expects %v",
+								sub.Directive, sub.src, n, act.argType)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// compare `file:line` numerically
+func srcLess(a, b string) bool {
+	af, al, _ := strings.Cut(a, ":")
+	bf, bl, _ := strings.Cut(b, ":")
+	if af != bf {
+		return af < bf
+	}
+	an, _ := strconv.Atoi(al)
+	bn, _ := strconv.Atoi(bl)
+	return an < bn
+}
@@ -0,0 +1,49 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestLint(t *testing.T) {
+	var g Grammar
+	g.Add("main", `expr stmt(s)`)
+	g.Add("expr", `expr "+" num`)                      // left recursion
+	g.Add("expr", `num`)                               //
+	g.Add("stmt", `opt "x"`)                           //
+	g.Add("stmt", ``)                                  // always matches
+	g.Add("stmt", `"y"`)                               // shadowed
+	g.Add("opt", `/a*/`)                               //
+	g.Add("loop", `opt(s) missing`)                    // unreachable, nullable repetition, refers to empty
+	g.Add("num", `/\d+/ /\d+/`)                        //
+	g.Add("pair", `num`, func(n int) int { return n }) // unreachable, a list can't be an int
+	g.Add("int", `/\d+/`, func(n int) int { return n })
+
+	lints := g.Lint("main")
+	var list []string
+	for _, l := range lints {
+		t.Logf("%v", l)
+		list = append(list, l.String())
+	}
+	all := strings.Join(list, "\n")
+	test.Contains(t, all, "lint_test.go:13: expr `expr \"+\" num`: left recursion: expr → expr")
+	test.Contains(t, all, "lint_test.go:17: stmt `\"y\"`: shadowed by `` at lint_test.go:16")
+	test.Contains(t, all, "lint_test.go:19: loop `opt(s) missing`: unreachable from main")
+	test.Contains(t, all, "lint_test.go:19: loop `opt(s) missing`: repetition of `opt` can match empty text")
+	test.Contains(t, all, "lint_test.go:12: main `expr stmt(s)`: repetition of `stmt` can match empty text")
+	test.Contains(t, all, "lint_test.go:19: loop `opt(s) missing`: refers to empty \"missing\"")
+	test.Contains(t, all, "lint_test.go:21: pair `num`: `/\\d+/ /\\d+/` at lint_test.go:20 returns a list of 2 items")
+	test.Contains(t, all, "lint_test.go:22: int `/\\d+/`: `/^\\d+/` returns a string")
+	test.EqualsGo(t, 10, len(lints))
+}
+
+func TestLintClean(t *testing.T) {
+	var g Grammar
+	g.Add("list", `"(" item(s ",") ")"`)
+	g.Add("item", `/\w+/`)
+	g.Add("item", `list`)
+	g.Add("item", ``)
+	test.EqualsGo(t, 0, len(g.Lint("list")))
+}
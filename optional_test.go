@@ -0,0 +1,90 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestOptional(t *testing.T) {
+	var g Grammar
+	if testing.Verbose() {
+		g.Log = t.Logf
+	}
+	g.Add("if", `"if" word [ "else" word ]`).Return(func(then, els string) []string {
+		return []string{then, els}
+	}).WS = Whitespaces
+	g.Add("word", `/\w+/`).WS = Whitespaces
+	test.NoError(t, g.Verify())
+	{
+		out, _, err := g.Parse("if", []byte(`if foo else bar`))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `["foo","bar"]`, out)
+	}
+	{
+		out, _, err := g.Parse("if", []byte(`if foo`))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `["foo",""]`, out)
+	}
+}
+
+func TestOptionalNested(t *testing.T) {
+	var g Grammar
+	g.Add("list", `num [ "," num [ "," num ] ]`)
+	g.Add("num", `/\d+/`)
+	test.NoError(t, g.Verify())
+	{
+		out, _, err := g.Parse("list", []byte(`1,2,3`))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `["1",["2","3"]]`, out)
+	}
+	{
+		out, _, err := g.Parse("list", []byte(`1,2`))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `["1",["2",null]]`, out)
+	}
+	{
+		out, _, err := g.Parse("list", []byte(`1`))
+		test.NoError(t, err)
+		test.EqualsJSON(t, `["1",null]`, out)
+	}
+}
+
+func TestOptionalSeparator(t *testing.T) {
+	var g Grammar
+	g.Add("list", `num(s [ /[,;]/ ]) <eof>`)
+	g.Add("silent", `num(s ~[ /[,;]/ ]) <eof>`)
+	g.Add("num", `/\d/`)
+	test.NoError(t, g.Verify())
+	out, _, err := g.Parse("list", []byte(`1;23`))
+	test.NoError(t, err)
+	test.EqualsJSON(t, `["1",";","2",null,"3"]`, out)
+	out, _, err = g.Parse("silent", []byte(`1;23`))
+	test.NoError(t, err)
+	test.EqualsJSON(t, `["1","2","3"]`, out)
+}
+
+func TestOptionalCommit(t *testing.T) {
+	var g Grammar
+	g.Add("if", `"if" word [ "else" + word ]`).WS = Whitespaces
+	g.Add("word", `/\w+/`).WS = Whitespaces
+	_, _, err := g.Parse("if", []byte(`if foo else`))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "expected word")
+}
+
+func TestOptionalInvalid(t *testing.T) {
+	for _, d := range []string{
+		`"if" [ "else"`,
+		`"if" ![ "else" ]`,
+	} {
+		g := Grammar{}
+		p := Prod{
+			g:         &g,
+			Directive: d,
+		}
+		_, err := p.build("")
+		t.Logf("%s: %v", d, err)
+		test.Error(t, err)
+	}
+}
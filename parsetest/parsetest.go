@@ -0,0 +1,116 @@
+// Package parsetest runs a grammar over the `*.in` files in a directory, and compares the results with the
+// `.golden` files next to them, or the errors with the `.err` files:
+//
+//	func TestGrammar(t *testing.T) {
+//		parsetest.Run(t, g, "expr", parsetest.Options{})
+//	}
+//
+// run the tests with `-update` to (re)write the expected files from the current results
+//
+// a `.golden` file has the result as indented JSON (or as parse.Indented() text), a `.err` file has
+// `line:col: message` for where the parse failed
+package parsetest
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden and .err files with the current results")
+
+type Options struct {
+	Dir     string // where the files are (default "testdata")
+	Pattern string // which files to parse (default "*.in")
+	Format  string // how the results are written to the `.golden` files: "json" (default) or "text"
+}
+
+// parse each file, in a subtest, and compare the result with the expected one
+func Run(t *testing.T, g *parse.Grammar, start string, opts Options) {
+	t.Helper()
+	if opts.Dir == "" {
+		opts.Dir = "testdata"
+	}
+	if opts.Pattern == "" {
+		opts.Pattern = "*.in"
+	}
+	files, err := filepath.Glob(filepath.Join(opts.Dir, opts.Pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no files matching %s", filepath.Join(opts.Dir, opts.Pattern))
+	}
+	for _, file := range files {
+		base := strings.TrimSuffix(file, filepath.Ext(file))
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			text, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, _, perr := g.ParseFile(start, file, text)
+			var got, expFile, otherFile string
+			if perr != nil {
+				got, expFile, otherFile = Located(text, perr)+"\n", base+".err", base+".golden"
+			} else {
+				got, err = format(out, opts.Format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expFile, otherFile = base+".golden", base+".err"
+			}
+			if *update {
+				if err := os.WriteFile(expFile, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				os.Remove(otherFile)
+				return
+			}
+			exp, err := os.ReadFile(expFile)
+			if errors.Is(err, os.ErrNotExist) {
+				switch _, err := os.Stat(otherFile); {
+				case err != nil:
+					t.Fatalf("missing %s, run with -update to accept the result:\n%s", expFile, got)
+				case perr != nil:
+					t.Fatalf("unexpected error %s", strings.TrimSpace(got))
+				default:
+					t.Fatalf("expected the error in %s, got:\n%s", otherFile, got)
+				}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(exp) != got {
+				t.Errorf("%s differs, run with -update to accept the result\nexpected:\n%s\ngot:\n%s", expFile, exp, got)
+			}
+		})
+	}
+}
+
+// the error as `line:col: message`, using the offset of a parse.Error
+func Located(text []byte, err error) string {
+	var perr *parse.Error
+	if !errors.As(err, &perr) {
+		return fmt.Sprintf("0:0: %v", err)
+	}
+	line, col := parse.NewPos("", text).Src.LineCol(perr.At())
+	return fmt.Sprintf("%d:%d: %v", line, col, errors.Unwrap(perr))
+}
+
+func format(out any, f string) (string, error) {
+	switch f {
+	case "", "json":
+		j, err := json.MarshalIndent(out, "", "  ")
+		return string(j) + "\n", err
+	case "text":
+		return parse.Indented(out)
+	}
+	return "", fmt.Errorf("unknown format %q", f)
+}
@@ -0,0 +1,38 @@
+package parsetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go/default_grammar"
+)
+
+func TestRun(t *testing.T) {
+	Run(t, default_grammar.New(), "expr", Options{})
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	test.NoError(t, os.WriteFile(filepath.Join(dir, "a.x"), []byte("1*2"), 0644))
+	test.NoError(t, os.WriteFile(filepath.Join(dir, "b.x"), []byte("1*"), 0644))
+	test.NoError(t, os.WriteFile(filepath.Join(dir, "b.golden"), []byte("stale"), 0644))
+
+	*update = true
+	defer func() { *update = false }()
+	opts := Options{Dir: dir, Pattern: "*.x", Format: "text"}
+	Run(t, default_grammar.New(), "expr", opts)
+
+	golden, err := os.ReadFile(filepath.Join(dir, "a.golden"))
+	test.NoError(t, err)
+	test.EqualsGo(t, "left: \"1\"\nop: \"*\"\nright: \"2\"\n", string(golden))
+	_, err = os.Stat(filepath.Join(dir, "b.golden"))
+	test.Error(t, err)
+	msg, err := os.ReadFile(filepath.Join(dir, "b.err"))
+	test.NoError(t, err)
+	test.EqualsGo(t, "1:2: unparsed: \"*\"\n", string(msg))
+
+	*update = false
+	Run(t, default_grammar.New(), "expr", opts)
+}
@@ -0,0 +1,357 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// add the rules of a PEG grammar, in the PEG.js/pigeon syntax
+// labels, `$` and actions in `{ ... }` are ignored, semantic predicates (`&{ ... }`) are not supported
+// whitespaces must be explicit, like in PEG
+// each element of a sequence produces an item, so actions can be attached later using Alt(name).Prods()
+func (this *Grammar) ImportPEG(fileName string, text []byte) error {
+	return this.importing(func() error { return this.importPEG(fileName, text) })
+}
+
+func (this *Grammar) importPEG(fileName string, text []byte) error {
+	sc := &pegScanner{file: fileName, s: string(text)}
+	sc.ws()
+	if sc.peek("{") { // initializer
+		if err := sc.code(); err != nil {
+			return err
+		}
+	}
+	imp := importer{g: this}
+	for {
+		sc.ws()
+		if sc.at == len(sc.s) {
+			return nil
+		}
+		src := sc.src()
+		name, ok := sc.ruleStart()
+		if !ok {
+			return sc.errorf("expected a rule")
+		}
+		if len(this.Alt(name).prods) > 0 {
+			return ctx.NewErrorf(nil, "%s: rule %s already defined", src, name)
+		}
+		n, err := sc.choice()
+		if err != nil {
+			return err
+		}
+		sc.ws()
+		if sc.peek(";") {
+			sc.at++
+		}
+		if err := imp.add(name, src, n); err != nil {
+			return err
+		}
+	}
+}
+
+type pegScanner struct {
+	file string
+	s    string
+	at   int
+}
+
+var (
+	pegIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	pegDef   = regexp.MustCompile(`^(=|<-|←)`)
+)
+
+func (this *pegScanner) src() string {
+	return fmt.Sprintf("%s:%d", this.file, strings.Count(this.s[0:this.at], "\n")+1)
+}
+
+func (this *pegScanner) errorf(f string, args ...any) error {
+	rem := this.s[this.at:]
+	if len(rem) > 20 {
+		rem = rem[0:20]
+	}
+	return ctx.NewErrorf(nil, "%s: %s at %q", this.src(), fmt.Sprintf(f, args...), rem)
+}
+
+func (this *pegScanner) peek(s string) bool {
+	return strings.HasPrefix(this.s[this.at:], s)
+}
+
+// skip whitespaces and comments
+func (this *pegScanner) ws() {
+	for this.at < len(this.s) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(this.s[this.at])):
+			this.at++
+		case this.peek("//"):
+			for this.at < len(this.s) && this.s[this.at] != '\n' {
+				this.at++
+			}
+		case this.peek("/*"):
+			end := strings.Index(this.s[this.at+2:], "*/")
+			if end < 0 {
+				this.at = len(this.s)
+			} else {
+				this.at += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// `name "display name"? =`, returns false and doesn't move if not a rule definition
+func (this *pegScanner) ruleStart() (string, bool) {
+	at := this.at
+	m := pegIdent.FindString(this.s[this.at:])
+	if m == "" {
+		return "", false
+	}
+	this.at += len(m)
+	this.ws()
+	if this.peek(`"`) || this.peek(`'`) {
+		if _, err := this.literal(); err != nil {
+			this.at = at
+			return "", false
+		}
+		this.ws()
+	}
+	def := pegDef.FindString(this.s[this.at:])
+	if def == "" {
+		this.at = at
+		return "", false
+	}
+	this.at += len(def)
+	return m, true
+}
+
+func (this *pegScanner) choice() (*gnode, error) {
+	var list []*gnode
+	for {
+		n, err := this.sequence()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+		this.ws()
+		if !this.peek("/") {
+			return gnodeOf(gAlt, list), nil
+		}
+		this.at++
+	}
+}
+
+func (this *pegScanner) sequence() (*gnode, error) {
+	list := []*gnode{}
+	for {
+		this.ws()
+		if this.at == len(this.s) || strings.ContainsRune("/);", rune(this.s[this.at])) {
+			break
+		}
+		if this.peek("{") { // action
+			if err := this.code(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		at := this.at
+		if _, ok := this.ruleStart(); ok {
+			this.at = at // it's the next rule
+			break
+		}
+		n, err := this.labeled()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return gnodeOf(gSeq, list), nil
+}
+
+func (this *pegScanner) labeled() (*gnode, error) {
+	at := this.at
+	if m := pegIdent.FindString(this.s[this.at:]); m != "" {
+		this.at += len(m)
+		this.ws()
+		if this.peek(":") {
+			this.at++
+			this.ws()
+		} else {
+			this.at = at
+		}
+	}
+	if this.peek("$") { // text of the match, which is what we return anyway
+		this.at++
+		this.ws()
+	}
+	switch {
+	case this.peek("&{"), this.peek("!{"):
+		return nil, this.errorf("semantic predicates are not supported")
+	case this.peek("&"), this.peek("!"):
+		kind := map[byte]gkind{'&': gAnd, '!': gNot}[this.s[this.at]]
+		this.at++
+		this.ws()
+		n, err := this.suffixed()
+		if err != nil {
+			return nil, err
+		}
+		return &gnode{kind: kind, sub: []*gnode{n}}, nil
+	}
+	return this.suffixed()
+}
+
+func (this *pegScanner) suffixed() (*gnode, error) {
+	n, err := this.primary()
+	if err != nil {
+		return nil, err
+	}
+	if this.at < len(this.s) {
+		switch this.s[this.at] {
+		case '?':
+			this.at++
+			return &gnode{kind: gRep, sub: []*gnode{n}, min: 0, max: 1}, nil
+		case '*':
+			this.at++
+			return &gnode{kind: gRep, sub: []*gnode{n}, min: 0, max: -1}, nil
+		case '+':
+			this.at++
+			return &gnode{kind: gRep, sub: []*gnode{n}, min: 1, max: -1}, nil
+		}
+	}
+	return n, nil
+}
+
+func (this *pegScanner) primary() (*gnode, error) {
+	if this.at == len(this.s) {
+		return nil, this.errorf("expected an expression")
+	}
+	switch this.s[this.at] {
+	case '(':
+		this.at++
+		n, err := this.choice()
+		if err != nil {
+			return nil, err
+		}
+		this.ws()
+		if !this.peek(")") {
+			return nil, this.errorf("expected `)`")
+		}
+		this.at++
+		return n, nil
+	case '"', '\'':
+		s, err := this.literal()
+		if err != nil {
+			return nil, err
+		}
+		return &gnode{kind: gLit, text: s, fold: this.fold()}, nil
+	case '[':
+		re, err := this.class()
+		if err != nil {
+			return nil, err
+		}
+		if this.fold() {
+			re = "(?i)" + re
+		}
+		return &gnode{kind: gRE, text: re}, nil
+	case '.':
+		this.at++
+		return &gnode{kind: gRE, text: `(?s:.)`}, nil
+	}
+	if m := pegIdent.FindString(this.s[this.at:]); m != "" {
+		this.at += len(m)
+		return &gnode{kind: gRef, text: m}, nil
+	}
+	return nil, this.errorf("unexpected text")
+}
+
+// the `i` suffix, for case-insensitive matching
+func (this *pegScanner) fold() bool {
+	if this.peek("i") && (this.at+1 == len(this.s) || !pegIdent.MatchString(this.s[this.at+1:])) {
+		this.at++
+		return true
+	}
+	return false
+}
+
+// a quoted string, with the escapes decoded
+func (this *pegScanner) literal() (string, error) {
+	quote := this.s[this.at]
+	rem := this.s[this.at+1:]
+	var b strings.Builder
+	for {
+		if rem == "" {
+			return "", this.errorf("unterminated string")
+		}
+		if rem[0] == quote {
+			this.at = len(this.s) - len(rem) + 1
+			return b.String(), nil
+		}
+		r, _, tail, err := strconv.UnquoteChar(rem, quote)
+		if err != nil {
+			return "", this.errorf("invalid string: %v", err)
+		}
+		b.WriteRune(r)
+		rem = tail
+	}
+}
+
+// a character class, converted to a Go regexp
+func (this *pegScanner) class() (string, error) {
+	var b strings.Builder
+	b.WriteByte('[')
+	i := this.at + 1
+	for ; i < len(this.s) && this.s[i] != ']'; i++ {
+		switch {
+		case this.s[i] == '/':
+			b.WriteString(`\/`)
+		case this.s[i] == '\\' && i+5 < len(this.s) && this.s[i+1] == 'u':
+			b.WriteString(`\x{` + this.s[i+2:i+6] + `}`)
+			i += 5
+		case this.s[i] == '\\' && i+1 < len(this.s):
+			b.WriteString(this.s[i : i+2])
+			i++
+		default:
+			b.WriteByte(this.s[i])
+		}
+	}
+	if i == len(this.s) {
+		return "", this.errorf("unterminated character class")
+	}
+	b.WriteByte(']')
+	this.at = i + 1
+	if _, err := regexp.Compile(b.String()); err != nil {
+		return "", this.errorf("invalid character class: %v", err)
+	}
+	return b.String(), nil
+}
+
+// skip a `{ ... }` code block
+func (this *pegScanner) code() error {
+	depth := 0
+	for this.at < len(this.s) {
+		switch c := this.s[this.at]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				this.at++
+				return nil
+			}
+		case '"', '\'', '`':
+			for this.at++; this.at < len(this.s) && this.s[this.at] != c; this.at++ {
+				if this.s[this.at] == '\\' {
+					this.at++
+				}
+			}
+			if this.at >= len(this.s) {
+				return this.errorf("unterminated string in code block")
+			}
+		}
+		this.at++
+	}
+	return this.errorf("unterminated code block")
+}
@@ -0,0 +1,102 @@
+package parse
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestImportPEG(t *testing.T) {
+	var g Grammar
+	err := g.ImportPEG("calc.peg", []byte(`
+{
+  // initializer, ignored
+  function sum(a, b) { return a + b; }
+}
+
+start = _ e:additive _ { return e; }
+
+additive "addition"
+  = left:multiplicative _ "+" _ right:additive { return sum(left, right); }
+  / multiplicative
+
+multiplicative
+  = left:primary _ "*" _ right:multiplicative { return left * right; }
+  / primary
+
+primary
+  = integer
+  / "(" _ additive:additive _ ")" { return additive; }
+
+/* a number, but not a keyword */
+integer = !"nan"i digits:$[0-9]+ &(_ ([+*)] / !.)) { return parseInt(digits, 10); }
+
+_ = [ \t\n]*
+`))
+	test.NoError(t, err)
+	test.NoError(t, g.Verify())
+	t.Logf("\n%s", g.Dump())
+
+	add := g.Alt("additive").Prods()
+	add[0].Return(func(l int, _ any, _ string, _ any, r int) int { return l + r })
+	mul := g.Alt("multiplicative").Prods()
+	mul[0].Return(func(l int, _ any, _ string, _ any, r int) int { return l * r })
+	g.Alt("primary").Prods()[1].Return(func(_ string, _ any, v int, _ any, _ string) int { return v })
+	g.Alt("integer").Prods()[0].Return(func(p Pos, _ []string) (int, error) {
+		return strconv.Atoi(p.Extract(0))
+	})
+	g.Alt("start").Prods()[0].Return(func(_ any, v int, _ any) int { return v })
+
+	out, _, err := g.Parse("start", []byte(" 2 * (3 + 4) + 1\n"))
+	test.NoError(t, err)
+	test.EqualsGo(t, 15, out)
+
+	_, _, err = g.Parse("start", []byte("2 3"))
+	test.Error(t, err)
+}
+
+// a failed import leaves the grammar as it was
+func TestImportPEGFailed(t *testing.T) {
+	var g Grammar
+	g.Add("word", `/[a-z]+/`)
+	dump := g.Dump()
+	err := g.ImportPEG("x.peg", []byte("list <- word (',' ' '? word)*\nnum <- [0-9]+\nlist <- 'x'\n"))
+	test.Error(t, err)
+	test.Contains(t, err.Error(), "rule list already defined")
+	test.EqualsGo(t, dump, g.Dump())
+	test.NoError(t, g.Verify())
+}
+
+func TestImportPEGSyntax(t *testing.T) {
+	var g Grammar
+	test.NoError(t, g.ImportPEG("x.peg", []byte(`
+word <- [a-z]i+ ('-' [a-z]i+)? ; // pigeon style
+str ← '"' chars:(!'"' .)* '"'
+`)))
+	_, _, err := g.Parse("word", []byte("Foo-bar"))
+	test.NoError(t, err)
+	_, _, err = g.Parse("str", []byte(`"a/b"`))
+	test.NoError(t, err)
+	_, _, err = g.Parse("str", []byte(`"a"b"`))
+	test.Error(t, err)
+
+	err = g.ImportPEG("bad.peg", []byte("a = &{ return true } 'x'"))
+	test.Contains(t, err.Error(), "bad.peg:1: semantic predicates are not supported")
+}
+
+func TestLookahead(t *testing.T) {
+	var g Grammar
+	g.Add("kw", `/\w+/ &"("`)
+	g.Add("call", `kw "(" ")"`)
+	out, _, err := g.Parse("call", []byte("foo()"))
+	test.NoError(t, err)
+	test.EqualsGo(t, "foo", out)
+	_, _, err = g.Parse("kw", []byte("foo"))
+	test.Error(t, err)
+
+	g.Add("ident", `!kw /\w+/`)
+	g.Add("ident", `kw`)
+	_, _, err = g.Parse("ident", []byte("bar"))
+	test.NoError(t, err)
+}
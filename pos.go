@@ -4,12 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
-
-	"github.com/ohait/forego/ctx"
-	"github.com/ohait/forego/enc"
 )
 
 type Pos struct {
@@ -76,6 +74,29 @@ type Stats struct {
 	ParseTime       time.Duration
 	BacktrackCount  int
 	BacktrackAmount int // how many bytes were backtracked
+	Reused          int // how many rule results were reused from a previous parse, see Tree
+
+	// the farthest offset where a terminal was tried, and what was expected there
+	// when the parse fails, this is usually where the input is wrong
+	Farthest int
+	Expected []string
+}
+
+// record a terminal which didn't match at the given offset
+func (this *Stats) expect(at int, what string) {
+	if at < this.Farthest {
+		return
+	}
+	if at > this.Farthest {
+		this.Farthest = at
+		this.Expected = nil
+	}
+	for _, e := range this.Expected {
+		if e == what {
+			return
+		}
+	}
+	this.Expected = append(this.Expected, what)
 }
 
 type pos struct {
@@ -85,9 +106,22 @@ type pos struct {
 	at     int
 	end    int
 	stack  []string
-	commit bool // true if the current production is committed, used for errors
+	rules  *ruleFrame // the rules being parsed, for the errors
+	commit bool       // true if the current production is committed, used for errors
 	p      *Prod
 	stats  *Stats
+
+	inverted bool // inside a negative lookahead, failures are not expected terminals
+
+	indents []int // indentation stack, used by `<indent>` and `<dedent>`
+
+	toks []Token // set if the grammar has a Lexer
+
+	cst *[]*Node // if set, nodes are added here (see ParseCST)
+
+	memo *memo // if set, the results of the rules are reused (see Tree)
+
+	complete *completer // if set, the terminals tried at the cursor are collected (see CompletionsAt)
 }
 
 func (this *pos) Log(f string, args ...any) {
@@ -112,15 +146,29 @@ func (this *pos) Log(f string, args ...any) {
 	}
 }
 
-func (this *pos) Rem(max int) string {
-	rem := this.src.bytes[this.at:]
-	if len(rem) > max {
-		rem = rem[0:max]
+// record a failed terminal, see Stats.Expected
+func (this *pos) expect(act action) {
+	if this.inverted {
+		return
+	}
+	if this.stats != nil {
+		what := act.expected()
+		if act.p != nil && act.p.expect != "" {
+			what = act.p.expect
+		}
+		this.stats.expect(this.at, what)
+	}
+	if this.complete != nil {
+		this.complete.add(this, act)
 	}
-	return string(rem)
+}
+
+func (this *pos) Rem(max int) string {
+	return this.src.rem(this.at, max)
 }
 
 func (this *pos) IgnoreRE(re *regexp.Regexp, negative bool) error {
+	this.lookRE(re)
 	m := re.Find(this.src.bytes[this.at:])
 	if m == nil {
 		if negative {
@@ -141,6 +189,7 @@ func (this *pos) IgnoreRE(re *regexp.Regexp, negative bool) error {
 }
 
 func (this *pos) ConsumeRE(re *regexp.Regexp, negative bool) (string, *Error) {
+	this.lookRE(re)
 	m := re.FindIndex(this.src.bytes[this.at:])
 	if m == nil {
 		if negative {
@@ -159,12 +208,38 @@ func (this *pos) ConsumeRE(re *regexp.Regexp, negative bool) (string, *Error) {
 		this.Log("❌ NEG AHEAD %q", out)
 		return "", this.NewErrorf("unwanted /%v/", re)
 	} else {
+		this.cstLeaf(this.at, this.at+m[1])
 		this.at += m[1]
 		this.Log("✅ CONSUMED /%v/ %q (%v)", re, out, m[1])
 		return string(out), nil
 	}
 }
 
+// skip whatever is before the next terminal
+func (this *pos) skip(ws *regexp.Regexp) *Error {
+	if this.g.Lexer != nil {
+		this.skipToToken()
+		return nil
+	}
+	if ws != nil {
+		err := this.IgnoreRE(ws, false)
+		if err != nil {
+			return this.NewErrorf("can't consume whitespace: %v", err)
+		}
+	}
+	return nil
+}
+
+// split the source into tokens, if the grammar has a Lexer
+func (this *pos) tokenize() *Error {
+	if this.g.Lexer == nil {
+		return nil
+	}
+	toks, err := this.g.Lexer.tokenize(this.file, this.src)
+	this.toks = toks
+	return err
+}
+
 func (this *pos) push(n string) {
 	this.stack = append(this.stack, n)
 }
@@ -177,7 +252,83 @@ func (this *pos) pop() {
 // first that succeed is returned
 // if none succeed the first error is returned
 func (this *pos) consumeProds(prods ...*Prod) (any, *Error) {
+	if this.memo != nil && this.cst == nil {
+		return this.memo.consume(this, prods)
+	}
+	return this.runProds(prods)
+}
+
+// like consumeProds, without looking at the memo
+func (this *pos) runProds(prods []*Prod) (any, *Error) {
 	this.stats.Alternations++
+	if len(prods) == 0 {
+		err := this.NewErrorf("no productions to parse")
+		err.Kind = UnknownRule
+		return nil, err
+	}
+	if this.g.MaxDepth > 0 && len(this.stack) >= this.g.MaxDepth {
+		err := this.NewErrorf("more than %d nested rules", this.g.MaxDepth)
+		err.Kind = Limit
+		err.commit = true // no alternative would do better
+		return nil, err
+	}
+	if alt := this.g.alts[prods[0].Name]; alt != nil && alt.label != "" {
+		return this.runLabeled(alt.label, prods)
+	}
+	return this.traceProds(prods)
+}
+
+// like runProds, but if nothing was matched the rule is expected as a whole, see Alts.Label()
+func (this *pos) runLabeled(label string, prods []*Prod) (any, *Error) {
+	q := *this
+	q.cst = nil
+	q.skip(prods[0].ws())
+	start := q.at
+
+	farthest, expected := this.stats.Farthest, this.stats.Expected
+	this.stats.Farthest, this.stats.Expected = -1, nil
+	out, err := this.traceProds(prods)
+	inner, what := this.stats.Farthest, this.stats.Expected
+	this.stats.Farthest, this.stats.Expected = farthest, expected
+	if inner >= 0 && inner <= start {
+		inner, what = start, []string{label}
+	}
+	if !this.inverted {
+		for _, w := range what {
+			this.stats.expect(inner, w)
+		}
+	}
+	if err != nil && err.Pos.End <= start {
+		e := *err
+		e.Err = fmt.Errorf("expected %s got %q", label, this.src.rem(start, 80))
+		e.Pos.From, e.Pos.End = start, start
+		e.rules = &ruleFrame{prods[0].Name, this.rules}
+		e.custom = true
+		err = &e
+	}
+	return out, err
+}
+
+// like runProds, with the rule traced if needed
+func (this *pos) traceProds(prods []*Prod) (any, *Error) {
+	if this.g.Tracer == nil {
+		out, _, err := this.tryProds(prods)
+		return out, err
+	}
+	from := this.at
+	this.trace(Event{Kind: EnterRule, Rule: prods[0].Name, Pos: Pos{From: from, End: from}})
+	out, n, err := this.tryProds(prods)
+	e := Event{Kind: ExitRule, Rule: prods[0].Name, Alt: n, Pos: Pos{From: from, End: this.at}, Result: out}
+	if err != nil {
+		e.Pos.End = err.Pos.End
+		e.Err = err
+	}
+	this.trace(e)
+	return out, err
+}
+
+// like consumeProds, also returns the index of the production which matched
+func (this *pos) tryProds(prods []*Prod) (any, int, *Error) {
 	switch len(prods) {
 	case 0:
 		panic("no alternatives") // Verify() would have caught this
@@ -186,10 +337,19 @@ func (this *pos) consumeProds(prods ...*Prod) (any, *Error) {
 		p := *this
 		p.commit = false
 		p.push("")
+		p.rules = &ruleFrame{prod.Name, p.rules}
+		kids := p.cstEnter()
 		p.Log("trying %s[%s] `%s`", prod.Name, prod.src, prod.Directive)
+		if p.g.Tracer != nil {
+			p.trace(prod.event(TryAlt, p.at, p.at))
+		}
 		out, err := prod.exec(&p)
+		if err == nil {
+			this.cstAdd(prod, kids, p.at)
+		}
 		this.at = p.at
-		return out, err
+		this.indents = p.indents
+		return out, 0, err
 	default:
 	}
 	var errs []*Error
@@ -197,62 +357,77 @@ func (this *pos) consumeProds(prods ...*Prod) (any, *Error) {
 		p := *this
 		p.commit = false
 		p.push(fmt.Sprintf("%s/%d", prod.Name, n))
+		p.rules = &ruleFrame{prod.Name, p.rules}
+		kids := p.cstEnter()
 		p.Log("trying %s/%d[%s] `%s` ", prod.Name, n, prod.src, prod.Directive)
+		if p.g.Tracer != nil {
+			p.trace(prod.event(TryAlt, p.at, p.at))
+		}
 		out, err := prod.exec(&p)
 
 		if err == nil {
+			this.cstAdd(prod, kids, p.at)
 			this.at = p.at
-			return out, nil
+			this.indents = p.indents
+			return out, n, nil
 		}
 		if err.commit {
 			p.Log("failed+commit %s[%s]: %v", prod.Name, prod.src, err)
 			this.at = p.at
-			return out, err
+			return out, n, err
 		}
 		this.stats.BacktrackAmount += p.at - this.at
 		this.stats.BacktrackCount++
 		p.Log("failed %s[%s]: %v", prod.Name, prod.src, err)
+		if p.g.Tracer != nil {
+			e := prod.event(Backtrack, this.at, max(p.at, err.Pos.End))
+			e.Err = err
+			p.trace(e)
+		}
 		errs = append(errs, err)
 	}
 	this.Log("can't find any production")
-	sort.Slice(errs, func(i, j int) bool {
-		return errs[i].at > errs[j].at
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Pos.End != errs[j].Pos.End {
+			return errs[i].Pos.End > errs[j].Pos.End
+		}
+		return errs[i].custom && !errs[j].custom // the grammar knows better
 	})
 	for _, e := range errs {
 		this.Log("» %v", e)
 	}
-	return nil, errs[0]
+	return nil, -1, errs[0]
 }
 
 func (this *pos) NewErrorf(f string, args ...any) *Error {
+	return this.newError(fmt.Errorf(f, args...), this.at)
+}
+
+// an error at the current position, about the text from the given offset
+func (this *pos) newError(err error, from int) *Error {
 	return &Error{
-		err: fmt.Errorf(f, args...),
-		// err: ctx.NewErrorf(nil, f, args...),
-		at:     this.at,
+		Kind:   Syntax,
+		Pos:    Pos{From: from, End: this.at, File: this.file, Src: this.src},
+		Err:    err,
 		commit: this.commit,
+		rules:  this.rules,
 	}
 }
 
-type Error struct {
-	err    error
-	at     int
-	commit bool
+// a rule being parsed, and the one which called it
+type ruleFrame struct {
+	name   string
+	parent *ruleFrame
 }
 
-var (
-	_ json.Marshaler = &Error{}
-	_ enc.Marshaler  = &Error{}
-)
-
-func (this Error) Error() string {
-	return fmt.Sprintf("%v at %d", this.err, this.at)
-}
-func (this Error) Unwrap() error { return this.err }
-
-func (this *Error) MarshalJSON() ([]byte, error) {
-	return json.Marshal(this.Error())
-}
-
-func (this *Error) MarshalNode(c ctx.C) (enc.Node, error) {
-	return enc.String(this.Error()), nil
+// the rules, from the outermost, without the internal ones
+func (this *ruleFrame) names() []string {
+	var out []string
+	for f := this; f != nil; f = f.parent {
+		if !strings.Contains(f.name, ",") {
+			out = append(out, f.name)
+		}
+	}
+	slices.Reverse(out)
+	return out
 }
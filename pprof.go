@@ -0,0 +1,155 @@
+package parse
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// write the profile in the pprof format, each alternative being a function, so `go tool pprof` can show
+// which rules are hot, and where they are called from
+// the sample values are the attempts, the backtracked bytes and the time excluding the sub rules
+func (this *Profiler) WritePprof(w io.Writer) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	strs := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		i, ok := strs[s]
+		if !ok {
+			i = int64(len(table))
+			strs[s] = i
+			table = append(table, s)
+		}
+		return i
+	}
+
+	var prof pbuf
+	for _, t := range [][2]string{{"attempts", "count"}, {"backtracked", "bytes"}, {"time", "nanoseconds"}} {
+		var vt pbuf
+		vt.int(1, str(t[0]))
+		vt.int(2, str(t[1]))
+		prof.msg(1, &vt)
+	}
+
+	keys := make([]string, 0, len(this.samples))
+	for k := range this.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := this.samples[k]
+		var sample pbuf
+		sample.packed(1, s.stack)
+		sample.packed(2, []uint64{uint64(s.attempts), uint64(s.backtracked), uint64(s.self.Nanoseconds())})
+		prof.msg(2, &sample)
+	}
+
+	type fn struct {
+		id  uint64
+		key profKey
+	}
+	var fns []fn
+	for k, id := range this.funcs {
+		fns = append(fns, fn{id, k})
+	}
+	sort.Slice(fns, func(i, j int) bool { return fns[i].id < fns[j].id })
+	for _, f := range fns {
+		// a location for each function, with the same id
+		e := this.entries[f.key]
+		file, line := e.Src, 0
+		if i := strings.LastIndex(e.Src, ":"); i > 0 {
+			file = e.Src[:i]
+			line, _ = strconv.Atoi(e.Src[i+1:])
+		}
+		var l pbuf
+		l.uint(1, f.id)
+		l.int(2, int64(line))
+		var loc pbuf
+		loc.uint(1, f.id)
+		loc.msg(4, &l)
+		prof.msg(4, &loc)
+
+		name := fmt.Sprintf("%s/%d", e.Rule, e.Alt)
+		var fun pbuf
+		fun.uint(1, f.id)
+		fun.int(2, str(name))
+		fun.int(3, str(name+" `"+e.Directive+"`"))
+		fun.int(4, str(file))
+		fun.int(5, int64(line))
+		prof.msg(5, &fun)
+	}
+
+	var pt pbuf
+	pt.int(1, str("time"))
+	pt.int(2, str("nanoseconds"))
+	prof.int(9, this.start.UnixNano())
+	prof.int(10, this.end.Sub(this.start).Nanoseconds())
+	prof.msg(11, &pt)
+	prof.int(12, 1)
+	prof.int(14, str("time")) // default_sample_type
+	for _, s := range table {
+		prof.str(6, s)
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(prof.b); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// a minimal protocol buffer encoder, enough for the pprof format
+type pbuf struct {
+	b []byte
+}
+
+func (this *pbuf) varint(x uint64) {
+	for x >= 0x80 {
+		this.b = append(this.b, byte(x)|0x80)
+		x >>= 7
+	}
+	this.b = append(this.b, byte(x))
+}
+
+func (this *pbuf) key(field, wireType int) {
+	this.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (this *pbuf) uint(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	this.key(field, 0)
+	this.varint(x)
+}
+
+func (this *pbuf) int(field int, x int64) {
+	this.uint(field, uint64(x))
+}
+
+func (this *pbuf) bytes(field int, b []byte) {
+	this.key(field, 2)
+	this.varint(uint64(len(b)))
+	this.b = append(this.b, b...)
+}
+
+func (this *pbuf) str(field int, s string) {
+	this.bytes(field, []byte(s))
+}
+
+func (this *pbuf) msg(field int, m *pbuf) {
+	this.bytes(field, m.b)
+}
+
+func (this *pbuf) packed(field int, xs []uint64) {
+	var p pbuf
+	for _, x := range xs {
+		p.varint(x)
+	}
+	this.bytes(field, p.b)
+}
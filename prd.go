@@ -0,0 +1,148 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+var (
+	prdRule  = regexp.MustCompile(`^(\w+)\s*:(.*)$`)
+	prdAlt   = regexp.MustCompile(`^\s+\|(.*)$`)
+	prdToken = regexp.MustCompile(`^(\w+)\s+(.*)$`)
+)
+
+// load a grammar from its textual form, usually a `.prd` file:
+//
+//	# comments start with `#`
+//	%ws /[ \t]*/        whitespaces to skip before the terminals, for the rules below
+//	%end /\s*/          trailing text to ignore, see Grammar.End
+//	%skip /\s*/         what the Lexer skips between tokens
+//	%token NUM /\d+/    add a token kind to the Lexer
+//	%class expr number  the highlight class of a rule, see Prod.Class()
+//	%class if kw "else" the highlight class of a terminal of the rule, see Prod.TermClass()
+//	%label num a number what the rule is called in the errors, see Alts.Label()
+//	expr: term "+" expr a production
+//	    | term          another alternative
+//	empty:              an empty production
+//
+// actions can be attached later using Alt(name).Prods()
+func LoadGrammar(fileName string, text []byte) (*Grammar, error) {
+	g := &Grammar{}
+	var ws *regexp.Regexp
+	rule := ""
+	var later [][4]string // src, pragma, rule and argument, applied once all the rules are added
+	for i, line := range strings.Split(string(text), "\n") {
+		src := fmt.Sprintf("%s:%d", fileName, i+1)
+		line = strings.TrimRight(line, " \t\r")
+		trim := strings.TrimSpace(line)
+		if trim == "" || strings.HasPrefix(trim, "#") {
+			continue
+		}
+		add := func(d string) error {
+			p, err := g.Alt(rule).add(src, strings.TrimSpace(d))
+			if err != nil {
+				return ctx.NewErrorf(nil, "%s: %s: %v", src, rule, err)
+			}
+			p.WS = ws
+			return nil
+		}
+		if strings.HasPrefix(line, "%") {
+			name, arg, _ := strings.Cut(line[1:], " ")
+			arg = strings.TrimSpace(arg)
+			var err error
+			switch name {
+			case "ws":
+				ws, err = prdRE(arg)
+			case "end":
+				g.End, err = prdRE(arg)
+			case "skip":
+				if g.Lexer == nil {
+					g.Lexer = &Lexer{}
+				}
+				g.Lexer.Skip, err = prdRE(arg)
+			case "token":
+				m := prdToken.FindStringSubmatch(arg)
+				if m == nil {
+					err = ctx.NewErrorf(nil, "expected `%%token NAME /re/`")
+					break
+				}
+				var re *regexp.Regexp
+				re, err = prdRE(m[2])
+				if err == nil {
+					if g.Lexer == nil {
+						g.Lexer = &Lexer{}
+					}
+					g.Lexer.Add(m[1], strings.TrimPrefix(re.String(), "^"))
+				}
+			case "class", "label":
+				r, value, _ := strings.Cut(arg, " ")
+				value = strings.TrimSpace(value)
+				if r == "" || value == "" {
+					err = ctx.NewErrorf(nil, "expected `%%%s rule %s`", name, name)
+					break
+				}
+				later = append(later, [4]string{src, name, r, value})
+			default:
+				err = ctx.NewErrorf(nil, "unknown pragma %%%s", name)
+			}
+			if err != nil {
+				return nil, ctx.NewErrorf(nil, "%s: %v", src, err)
+			}
+			continue
+		}
+		if m := prdRule.FindStringSubmatch(line); m != nil {
+			rule = m[1]
+			if err := add(m[2]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if m := prdAlt.FindStringSubmatch(line); m != nil && rule != "" {
+			if err := add(m[1]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, ctx.NewErrorf(nil, "%s: expected `rule: directive` or `| directive`, got %q", src, trim)
+	}
+	for _, c := range later {
+		alt := g.alts[c[2]]
+		if alt == nil {
+			return nil, ctx.NewErrorf(nil, "%s: no rule named %q", c[0], c[2])
+		}
+		switch c[1] {
+		case "class":
+			class, term, _ := strings.Cut(c[3], " ")
+			term = strings.TrimSpace(term)
+			found := false
+			for _, p := range alt.prods {
+				if term == "" {
+					p.Class(class)
+				} else if p.termClass(term, class) {
+					found = true
+				}
+			}
+			if term != "" && !found {
+				return nil, ctx.NewErrorf(nil, "%s: no terminal %s in rule %s", c[0], term, c[2])
+			}
+		case "label":
+			alt.Label(c[3])
+		}
+	}
+	return g, nil
+}
+
+// a `/regexp/`
+func prdRE(s string) (*regexp.Regexp, error) {
+	re, l, err := parseRE(s)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(s[l:]) != "" {
+		return nil, ctx.NewErrorf(nil, "unexpected %q after the regexp", s[l:])
+	}
+	return re, nil
+}
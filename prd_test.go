@@ -0,0 +1,42 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestLoadGrammar(t *testing.T) {
+	g, err := LoadGrammar("sql.prd", []byte(`
+# a tiny SQL
+%skip /\s*/
+%token IDENT /[a-z_]\w*/
+%token OP /[,*]/
+
+select: "select" cols "from" IDENT
+cols: "*"
+    | IDENT(s ",")
+`))
+	test.NoError(t, err)
+	test.NoError(t, g.Verify())
+	out, _, err := g.Parse("select", []byte("select a, b from t"))
+	test.NoError(t, err)
+	test.EqualsJSON(t, []any{[]any{"a", "b"}, "t"}, out)
+	test.EqualsGo(t, "sql.prd:9", g.Alt("cols").Prods()[1].src)
+
+	g, err = LoadGrammar("ws.prd", []byte("%ws /[ ]*/\n%end /\\n*/\npair: /\\w+/ \"=\" /\\w+/\n"))
+	test.NoError(t, err)
+	_, _, err = g.Parse("pair", []byte("a = b\n\n"))
+	test.NoError(t, err)
+}
+
+func TestLoadGrammarErrors(t *testing.T) {
+	_, err := LoadGrammar("bad.prd", []byte("a: /x/\n%foo /x/\n"))
+	test.Contains(t, err.Error(), "bad.prd:2: unknown pragma %foo")
+
+	_, err = LoadGrammar("bad.prd", []byte("  | /x/\n"))
+	test.Contains(t, err.Error(), "bad.prd:1: expected `rule: directive`")
+
+	_, err = LoadGrammar("bad.prd", []byte("a: <nope>\n"))
+	test.Contains(t, err.Error(), "bad.prd:1: a: unknown directive")
+}
@@ -0,0 +1,157 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// the error with `file:line:col: message` (skipping the blanks at the error), the lines of the source with the
+// text which failed underlined, and the rules which were being parsed:
+//
+//	input.x:2:9: expected /\d+/ got "* 2;\n"
+//	 2 | let y = * 2;
+//	   |         ^
+//	 in prog > stmt > value
+//
+// followed by the Suggestions, if any
+// tabs are expanded, and columns count the characters as they are displayed
+func (this Error) Pretty() string {
+	if this.Pos.Src == nil {
+		return this.Error()
+	}
+	var b strings.Builder
+	text := this.Pos.Src.bytes
+	from, end := this.span()
+	line, col := this.Pos.Src.LineCol(from)
+	if this.Pos.File != "" {
+		b.WriteString(this.Pos.File + ":")
+	}
+	fmt.Fprintf(&b, "%d:%d: %v\n", line, col, this.Err)
+
+	first, last := this.Pos.Src.Line(from), this.Pos.Src.Line(max(from, end-1))
+	gutter := len(strconv.Itoa(last))
+	show := func(n int) {
+		start := this.Pos.Src.lineStart(n)
+		stop := start
+		for stop < len(text) && text[stop] != '\n' {
+			stop++
+		}
+		lo := max(from, start)
+		for lo > from && lo < stop && (text[lo] == ' ' || text[lo] == '\t') {
+			lo++ // not the indentation of the following lines
+		}
+		src, marks := displayed(text[start:stop], lo-start, min(end, stop)-start)
+		fmt.Fprintf(&b, " %*d | %s\n", gutter, n, strings.TrimRight(src, "\r"))
+		fmt.Fprintf(&b, " %*s | %s\n", gutter, "", marks)
+	}
+	for n := first; n <= last; n++ {
+		if last-first > 4 && n == first+2 {
+			// too many lines, only the first and last 2
+			fmt.Fprintf(&b, " %*s | ...\n", gutter, "")
+			n = last - 2
+			continue
+		}
+		show(n)
+	}
+	if rules := this.rules.names(); len(rules) > 0 {
+		fmt.Fprintf(&b, " in %s\n", strings.Join(rules, " > "))
+	}
+	if hint := this.Hint(); hint != "" {
+		fmt.Fprintf(&b, " %s\n", hint)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// `%+v` is Pretty(), `%v` and `%s` are Error()
+func (this Error) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		io.WriteString(f, this.Pretty())
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", this.Error())
+	default:
+		io.WriteString(f, this.Error())
+	}
+}
+
+// the text to underline: from the start of the production which failed, or the word at the error
+func (this Error) span() (int, int) {
+	text := this.Pos.Src.bytes
+	from := min(this.Pos.From, this.Pos.End)
+	for from < this.Pos.End {
+		r, w := utf8.DecodeRune(text[from:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		from += w
+	}
+	if from < this.Pos.End {
+		return from, this.Pos.End
+	}
+	for from < len(text) && (text[from] == ' ' || text[from] == '\t') {
+		from++
+	}
+	end := from
+	for end < len(text) {
+		r, w := utf8.DecodeRune(text[end:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		end += w
+	}
+	return from, end
+}
+
+// the line as displayed (tabs expanded), and the marks under the bytes from..end (at least one)
+func displayed(line []byte, from, end int) (string, string) {
+	var src, marks strings.Builder
+	col := 0
+	mark := func(at, w int) {
+		c := " "
+		if at >= from && (at < end || at == from) {
+			c = "^"
+		}
+		marks.WriteString(strings.Repeat(c, w))
+	}
+	for at := 0; at < len(line); {
+		r, n := utf8.DecodeRune(line[at:])
+		w := runeWidth(r)
+		if r == '\t' {
+			w = 4 - col%4
+			src.WriteString(strings.Repeat(" ", w))
+		} else {
+			src.WriteRune(r)
+		}
+		mark(at, w)
+		col += w
+		at += n
+	}
+	if from >= len(line) {
+		mark(from, 1) // at the end of the line
+	}
+	return src.String(), strings.TrimRight(marks.String(), " ")
+}
+
+// how many columns the rune takes in a terminal
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r >= 0x1100 && r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f, // CJK
+		r >= 0xac00 && r <= 0xd7a3,                // Hangul
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60, // fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f, // emoji
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
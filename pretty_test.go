@@ -0,0 +1,99 @@
+package parse_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func TestPretty(t *testing.T) {
+	g, err := parse.LoadGrammar("let.prd", []byte(`%ws /\s*/
+prog: stmt(s) <eof>
+stmt: /let\b/ name "=" value ";"
+name: /\w+/
+value: /\d+/
+     | list
+list: "[" value(s ",") "]"
+`))
+	test.NoError(t, err)
+	g.Alt("list").Prods()[0].Return(func(list []any) (any, error) {
+		if len(list) > 2 {
+			return nil, fmt.Errorf("too many values")
+		}
+		return list, nil
+	})
+	pretty := func(text string) string {
+		_, _, err := g.ParseFile("prog", "input.x", []byte(text))
+		var perr *parse.Error
+		test.Assert(t, errors.As(err, &perr))
+		test.EqualsGo(t, perr.Pretty(), fmt.Sprintf("%+v", err))
+		test.EqualsGo(t, err.Error(), fmt.Sprintf("%v", err))
+		return perr.Pretty()
+	}
+
+	// tabs are expanded, the column in the header is in bytes
+	test.EqualsGo(t, `input.x:1:6: expected /^\w+/ got "π =\t*;\n"
+ 1 |     let π = *;
+   |         ^
+ in prog > stmt > name`, pretty("\tlet\tπ =\t*;\n"))
+
+	// wide characters take 2 columns, combining ones none
+	test.EqualsGo(t, `input.x:1:5: expected /^\w+/ got "日本 = 1;\n"
+ 1 | let 日本 = 1;
+   |     ^^^^
+ in prog > stmt > name`, pretty("let 日本 = 1;\n"))
+	test.EqualsGo(t, "input.x:1:9: expected /^\\d+/ got \"e\u0301;\\n\"\n"+
+		" 1 | let x = e\u0301;\n"+
+		"   |         ^^\n"+
+		" in prog > stmt > value", pretty("let x = e\u0301;\n"))
+
+	// the whole word is underlined, U+00E0 and U+0145 end with the bytes of U+00A0 and U+0085
+	test.EqualsGo(t, `input.x:1:9: expected /^\d+/ got "voilàxyz;\n"
+ 1 | let x = voilàxyz;
+   |         ^^^^^^^^^
+ in prog > stmt > value`, pretty("let x = voilàxyz;\n"))
+	test.EqualsGo(t, `input.x:1:9: expected /^\d+/ got "Ņabc;\n"
+ 1 | let x = Ņabc;
+   |         ^^^^^
+ in prog > stmt > value`, pretty("let x = Ņabc;\n"))
+
+	// the text given to a failing Return() function, without the indentation
+	test.EqualsGo(t, `input.x:1:9: too many values
+ 1 | let x = [1,
+   |         ^^^
+ 2 |   2,
+   |   ^^
+ 3 |   3];
+   |   ^^
+ in prog > stmt > value > list`, pretty("let x = [1,\n  2,\n  3];\n"))
+	test.EqualsGo(t, `input.x:1:9: too many values
+  1 | let x = [1,
+    |         ^^^
+  2 |   2,
+    |   ^^
+    | ...
+  9 |   9,
+    |   ^^
+ 10 |   10];
+    |   ^^^
+ in prog > stmt > value > list`, pretty("let x = [1,\n  2,\n  3,\n  4,\n  5,\n  6,\n  7,\n  8,\n  9,\n  10];\n"))
+
+	// at the end
+	test.EqualsGo(t, `input.x:1:10: expected /^;/ got ""
+ 1 | let x = 1
+   |          ^
+ in prog > stmt`, pretty("let x = 1"))
+
+	lex, err := parse.LoadGrammar("lex.prd", []byte(`%skip /\s*/
+%token NUM /\d+/
+prog: NUM(s)
+`))
+	test.NoError(t, err)
+	_, _, err = lex.ParseFile("prog", "", []byte("1 2\n3 x 4"))
+	test.EqualsGo(t, `2:3: invalid token "x 4"
+ 2 | 3 x 4
+   |   ^`, fmt.Sprintf("%+v", err))
+}
@@ -0,0 +1,255 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// generates text from a value, walking the directives of a grammar
+// productions with a Return() should have an Unreturn() which splits the value back into its items
+// productions without are expected to have the default shape: nil, the only item, or a list of items
+type Printer struct {
+	Grammar *Grammar
+
+	// added in between terminals (default " ")
+	Space string
+
+	// if set and returns true, no Space is added in between the two given terminals
+	Glue func(prev, next string) bool
+
+	// added for each `<indent>` level (default "\t")
+	Indent string
+}
+
+type printState struct {
+	out   []byte
+	level int
+	bol   bool
+	prev  string
+	depth int
+}
+
+// print the given value as the named production
+func (this *Printer) Print(name string, v any) (string, error) {
+	st := printState{bol: true}
+	err := this.print(&st, name, v)
+	if err != nil {
+		return "", err
+	}
+	return string(st.out), nil
+}
+
+func (this *Printer) print(st *printState, name string, v any) error {
+	alt := this.Grammar.alts[name]
+	if alt == nil || len(alt.prods) == 0 {
+		if l := this.Grammar.Lexer; l.Has(name) {
+			for _, r := range l.rules {
+				if r.kind == name {
+					return this.terminal(st, r.re, v)
+				}
+			}
+		}
+		return ctx.NewErrorf(nil, "no prod named %q", name)
+	}
+	if st.depth > 1000 {
+		return ctx.NewErrorf(nil, "too deep printing %q", name)
+	}
+	var errs []string
+	for _, p := range alt.prods {
+		items, err := p.unreturn(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.src, err))
+			continue
+		}
+		save := *st
+		st.depth++
+		err = this.printProd(st, p, items)
+		st.depth--
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", p.src, err))
+		*st = save
+		st.out = st.out[0:len(save.out)]
+	}
+	if len(alt.prods) == 1 {
+		return ctx.NewErrorf(nil, "can't print %T as %q: %s", v, name, errs[0])
+	}
+	return ctx.NewErrorf(nil, "can't print %T as %q: [%s]", v, name, strings.Join(errs, "; "))
+}
+
+func (this *Printer) printProd(st *printState, p *Prod, items []any) error {
+	j := 0
+	for _, act := range p.actions {
+		var v any
+		if !act.silent {
+			v = items[j]
+			j++
+		}
+		switch {
+		case act.commit, act.negative, act.ahead:
+		case act.builtin != "":
+			this.builtin(st, act.builtin)
+		case act.lit != "":
+			this.emit(st, act.lit)
+		case act.re != nil:
+			if act.silent {
+				if s, ok := reLiteral(act.re); ok {
+					this.emit(st, s)
+				}
+				continue
+			}
+			if err := this.terminal(st, act.re, v); err != nil {
+				return err
+			}
+		case act.prod != "":
+			if err := this.print(st, act.prod, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (this *Printer) terminal(st *printState, re *regexp.Regexp, v any) error {
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+	m := re.FindStringIndex(s)
+	if m == nil || m[1] != len(s) {
+		return ctx.NewErrorf(nil, "%q doesn't match /%s/", s, re)
+	}
+	this.emit(st, s)
+	return nil
+}
+
+func (this *Printer) builtin(st *printState, b string) {
+	switch b {
+	case "<eol>":
+		this.newline(st)
+	case "<bol>":
+		if !st.bol {
+			this.newline(st)
+		}
+	case "<indent>":
+		st.level++
+	case "<dedent>":
+		st.level--
+	}
+}
+
+func (this *Printer) newline(st *printState) {
+	st.out = append(st.out, '\n')
+	st.bol = true
+	st.prev = ""
+}
+
+func (this *Printer) emit(st *printState, s string) {
+	if s == "" {
+		return
+	}
+	if st.bol {
+		indent := this.Indent
+		if indent == "" {
+			indent = "\t"
+		}
+		st.out = append(st.out, strings.Repeat(indent, st.level)...)
+	} else if st.prev != "" && s[0] != '\n' && (this.Glue == nil || !this.Glue(st.prev, s)) {
+		space := this.Space
+		if space == "" {
+			space = " "
+		}
+		st.out = append(st.out, space...)
+	}
+	if strings.HasSuffix(s, "\n") {
+		st.out = append(st.out, s[0:len(s)-1]...)
+		this.newline(st)
+		return
+	}
+	st.out = append(st.out, s...)
+	st.bol = false
+	st.prev = s
+}
+
+// split the value into the items for the directive
+func (this *Prod) unreturn(v any) ([]any, error) {
+	if this.unret != nil {
+		return this.unret(v)
+	}
+	if this.ret != nil && this.retType != nil && this.items() > 1 {
+		return nil, ctx.NewErrorf(nil, "missing Unreturn()")
+	}
+	return this.defaultUnreturn(v)
+}
+
+// the opposite of what exec() does without a Return()
+func (this *Prod) defaultUnreturn(v any) ([]any, error) {
+	switch n := this.items(); n {
+	case 0:
+		if list, ok := toList(v); ok && len(list) == 0 {
+			return nil, nil
+		}
+		if v != nil && !reflect.ValueOf(v).IsZero() {
+			return nil, ctx.NewErrorf(nil, "expected nothing, got %T", v)
+		}
+		return nil, nil
+	case 1:
+		return []any{v}, nil
+	default:
+		list, ok := toList(v)
+		if !ok || len(list) != n {
+			return nil, ctx.NewErrorf(nil, "expected a list of %d items, got %T", n, v)
+		}
+		return list, nil
+	}
+}
+
+// used by internal repetitions, splits the first n items from the rest of the list
+func unreturnList(n int) func(v any) ([]any, error) {
+	return func(v any) ([]any, error) {
+		list, ok := toList(v)
+		if !ok && v != nil {
+			return nil, ctx.NewErrorf(nil, "expected a list, got %T", v)
+		}
+		switch {
+		case n == 0 && len(list) == 0:
+			return nil, nil
+		case n == 0:
+			return nil, ctx.NewErrorf(nil, "not empty")
+		case len(list) < n:
+			return nil, ctx.NewErrorf(nil, "expected at least %d items, got %d", n, len(list))
+		}
+		return append(list[0:n:n], list[n:]), nil
+	}
+}
+
+// converts any slice into a []any
+func toList(v any) ([]any, bool) {
+	if list, ok := v.([]any); ok {
+		return list, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// returns the text matched by the given regexp, if it can only match that
+func reLiteral(re *regexp.Regexp) (string, bool) {
+	s := strings.TrimPrefix(re.String(), "^")
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return "", false
+	}
+	return re.LiteralPrefix()
+}
@@ -0,0 +1,110 @@
+package parse
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestPrinterList(t *testing.T) {
+	var g Grammar
+	g.Add("list", `"(" item(s ",") ")"`).WS = Whitespaces
+	g.Add("item", `/\w+/`).WS = Whitespaces
+	g.Add("item", `list`)
+
+	pr := Printer{
+		Grammar: &g,
+		Glue: func(prev, next string) bool {
+			return prev == "(" || next == ")" || next == ","
+		},
+	}
+	v, _, err := g.Parse("list", []byte(`( a,(b ,c ) ,d)`))
+	test.NoError(t, err)
+	out, err := pr.Print("list", v)
+	test.NoError(t, err)
+	test.EqualsGo(t, `(a, (b, c), d)`, out)
+
+	v2, _, err := g.Parse("list", []byte(out))
+	test.NoError(t, err)
+	test.EqualsGo(t, v, v2)
+
+	_, err = pr.Print("list", []any{"a", 1.5})
+	test.Error(t, err)
+}
+
+func TestPrinterUnreturn(t *testing.T) {
+	var g Grammar
+	g.Add("add", `num add_`).Return(func(op any, tail []BinOp) any {
+		for _, b := range tail {
+			b.Left = op
+			op = b
+		}
+		return op
+	}).Unreturn(func(op any) (any, []BinOp) {
+		var tail []BinOp
+		for {
+			b, ok := op.(BinOp)
+			if !ok {
+				return op, tail
+			}
+			tail = append([]BinOp{{Op: b.Op, Right: b.Right}}, tail...)
+			op = b.Left
+		}
+	})
+	g.Add("add_", `/[\+\-]/ num add_`).Return(func(op string, right any, tail []BinOp) []BinOp {
+		return append([]BinOp{{Op: op, Right: right}}, tail...)
+	}).Unreturn(func(list []BinOp) (string, any, []BinOp, error) {
+		if len(list) == 0 {
+			return "", nil, nil, strconv.ErrSyntax
+		}
+		return list[0].Op, list[0].Right, list[1:], nil
+	}).WS = Whitespaces
+	g.Add("add_", ``).Return(func() []BinOp { return nil })
+	g.Add("num", `/\d+/`, strconv.Atoi).WS = Whitespaces
+	test.NoError(t, g.Verify())
+
+	v, _, err := g.Parse("add", []byte(`1+2 - 3`))
+	test.NoError(t, err)
+	pr := Printer{Grammar: &g}
+	out, err := pr.Print("add", v)
+	test.NoError(t, err)
+	test.EqualsGo(t, `1 + 2 - 3`, out)
+}
+
+func TestPrinterIndent(t *testing.T) {
+	g := Grammar{TabWidth: 4}
+	ws := regexp.MustCompile(`^[ \t]*`)
+	g.Add("file", `stmt(s) <eof>`).WS = ws
+	g.Add("stmt", `<samedent> name ":" ~/\n/ block`).Return(func(name string, block []*indentNode) *indentNode {
+		return &indentNode{name, block}
+	}).Unreturn(func(n *indentNode) (string, []*indentNode, error) {
+		if len(n.Block) == 0 {
+			return "", nil, strconv.ErrSyntax
+		}
+		return n.Name, n.Block, nil
+	}).WS = ws
+	g.Add("stmt", `<samedent> name ~/\n/`).Return(func(name string) *indentNode {
+		return &indentNode{Name: name}
+	}).Unreturn(func(n *indentNode) string {
+		return n.Name
+	}).WS = ws
+	g.Add("block", `<indent> stmt(s) <dedent>`).WS = ws
+	g.Add("name", `/\w+/`).WS = ws
+
+	v, _, err := g.Parse("file", []byte("a:\n  b\n  c:\n      d\ne\n"))
+	test.NoError(t, err)
+	pr := Printer{
+		Grammar: &g,
+		Indent:  "    ",
+		Glue:    func(prev, next string) bool { return next == ":" },
+	}
+	out, err := pr.Print("file", v)
+	test.NoError(t, err)
+	test.EqualsGo(t, "a:\n    b\n    c:\n        d\ne\n", out)
+
+	v2, _, err := g.Parse("file", []byte(out))
+	test.NoError(t, err)
+	test.EqualsJSON(t, v, v2)
+}
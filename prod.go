@@ -19,6 +19,9 @@ type Prod struct {
 	// override the above
 	wsFrom *Prod
 
+	// relative weight when generating random inputs (0 means 1), see Grammar.Generate()
+	Weight float64
+
 	// Set by Add()
 	Name string
 
@@ -34,6 +37,17 @@ type Prod struct {
 	// function to be used at the end of the production
 	ret     func(from int, at *pos, in []any) (any, error)
 	retType reflect.Type
+	retErr  bool         // the return function can fail
+	retSig  reflect.Type // the type of the function given to Return(), used by the code generator
+
+	// the opposite of ret, used by the Printer
+	unret func(v any) ([]any, error)
+
+	// highlight class of the text matched, see Class()
+	class string
+
+	// what the production expects, used in its errors instead of the items, see Expect()
+	expect string
 }
 
 type action struct {
@@ -45,9 +59,17 @@ type action struct {
 	p        *Prod
 	prod     string
 	re       *regexp.Regexp
-	negative bool // if true, make into a negative lookahead
+	lit      string // the text of a `"..."` directive
+	negative bool   // if true, make into a negative lookahead
+	ahead    bool   // if true, make into a positive lookahead, which doesn't consume any text
 
 	argType reflect.Type // if set, means a return function expect this to be of the given type
+
+	builtin string          // the `<...>` directive, if any
+	check   func(*pos) bool // evaluates the builtin directive, can move the position
+	message string          // the error of `<error:message>`, which always fails
+
+	class string // highlight class of the terminal, see Prod.TermClass()
 }
 
 func (this action) String() string {
@@ -58,6 +80,15 @@ func (this action) String() string {
 	if this.silent {
 		s = "~"
 	}
+	if this.negative {
+		s += "!"
+	}
+	if this.ahead {
+		s += "&"
+	}
+	if this.builtin != "" {
+		return s + this.builtin
+	}
 	if this.re != nil {
 		return s + "/" + this.re.String() + "/"
 	}
@@ -67,6 +98,37 @@ func (this action) String() string {
 	return s
 }
 
+// the item as written in the directive, as far as it can be rebuilt
+func (this action) item() string {
+	if this.commit {
+		return "+"
+	}
+	s := ""
+	if this.silent && this.lit == "" { // literals are always silent
+		s = "~"
+	}
+	if this.negative {
+		s += "!"
+	}
+	if this.ahead {
+		s += "&"
+	}
+	return s + this.expected()
+}
+
+// how the action is listed in Stats.Expected
+func (this action) expected() string {
+	switch {
+	case this.lit != "":
+		return `"` + this.lit + `"`
+	case this.builtin != "":
+		return this.builtin
+	case this.re != nil:
+		return "/" + strings.TrimPrefix(this.re.String(), "^") + "/"
+	}
+	return this.prod
+}
+
 func (this *Prod) ws() *regexp.Regexp {
 	if this.wsFrom != nil {
 		return this.wsFrom.ws()
@@ -78,31 +140,110 @@ func (this action) exec(p *pos) (any, *Error) {
 	if this.commit {
 		p.Log("commit %p", p)
 		p.commit = true
+		if p.g.Tracer != nil {
+			p.trace(this.p.event(Commit, p.at, p.at))
+		}
+		return nil, nil
+	}
+	if this.ahead {
+		q := *p
+		q.cst = nil // lookaheads don't add nodes
+		act := this
+		act.ahead = false
+		out, err := act.exec(&q)
+		if err != nil {
+			return nil, err
+		}
+		p.Log("✅ AHEAD %s", this)
+		return out, nil
+	}
+	if this.check != nil {
+		at := p.at
+		if err := p.skip(this.p.ws()); err != nil {
+			return nil, err
+		}
+		p.lookLines(p.at)
+		indents := p.indents
+		if this.check(p) == this.negative {
+			if !this.negative && this.message == "" {
+				p.expect(this)
+			}
+			p.at = at
+			p.indents = indents
+			p.Log("❌ FAIL %s", this)
+			if this.message != "" {
+				err := p.NewErrorf("%s", this.message)
+				err.custom = true
+				return nil, err
+			}
+			return nil, p.NewErrorf("expected %s got %q", this, p.Rem(80))
+		}
+		if this.negative {
+			p.at = at
+			p.indents = indents
+		}
+		p.Log("✅ %s", this)
 		return nil, nil
 	}
 	if this.re != nil {
-		ws := this.p.ws()
-		if ws != nil {
-			err := p.IgnoreRE(ws, false)
-			if err != nil {
-				return nil, p.NewErrorf("can't consume whitespace: %v", err)
+		if err := p.skip(this.p.ws()); err != nil {
+			return nil, err
+		}
+		var out string
+		var err *Error
+		if p.g.Lexer != nil {
+			out, err = p.ConsumeTokenRE(this.re, this.negative)
+		} else {
+			out, err = p.ConsumeRE(this.re, this.negative)
+		}
+		if err != nil && !this.negative {
+			p.expect(this)
+		}
+		if err == nil && !this.negative {
+			p.cstClass(this.class)
+			if p.g.Tracer != nil {
+				p.traceConsume(this.expected(), out)
 			}
 		}
-
-		out, err := p.ConsumeRE(this.re, this.negative)
 		return out, err
 	}
 	if this.prod != "" {
 		alt := this.p.g.alts[this.prod]
-		if len(alt.prods) == 0 {
-			return nil, p.NewErrorf("no prod with name %q", this.prod)
+		if alt == nil || len(alt.prods) == 0 {
+			if p.g.Lexer.Has(this.prod) {
+				out, err := p.ConsumeKind(this.prod, this.negative)
+				if err != nil && !this.negative {
+					p.expect(this)
+				}
+				if err == nil && !this.negative {
+					p.cstClass(this.class)
+					if p.g.Tracer != nil {
+						p.traceConsume(this.prod, out)
+					}
+				}
+				return out, err
+			}
+			err := p.NewErrorf("no prod with name %q", this.prod)
+			err.Kind, err.Rule = UnknownRule, this.prod
+			return nil, err
+		}
+		if this.negative {
+			q := *p
+			q.cst = nil
+			q.inverted = true
+			if _, err := q.consumeProds(alt.prods...); err == nil {
+				p.Log("❌ NEG AHEAD %s", this.prod)
+				return nil, p.NewErrorf("unwanted %s", this.prod)
+			}
+			p.Log("✅ NEG AHEAD %s", this.prod)
+			return nil, nil
 		}
 		return p.consumeProds(alt.prods...)
 	}
 	return nil, p.NewErrorf("empty action")
 }
 
-// helper
+// parse the whole text with this production only, the errors are like the ones of Grammar.Parse()
 func (this *Prod) Parse(fname string, in []byte, end *regexp.Regexp) (any, error) {
 	p := &pos{
 		g:     this.g,
@@ -110,15 +251,24 @@ func (this *Prod) Parse(fname string, in []byte, end *regexp.Regexp) (any, error
 		src:   &Src{bytes: in},
 		stats: &Stats{},
 	}
+	if err := p.tokenize(); err != nil {
+		return nil, err.finish(*p.stats)
+	}
 	out, err := p.consumeProds(this)
 	if err != nil {
-		return nil, err
+		return nil, err.finish(*p.stats)
 	}
 	if end != nil {
 		p.IgnoreRE(end, false)
 	}
+	if p.g.Lexer != nil {
+		p.skipToToken()
+	}
 	if p.Rem(10) != "" {
-		return out, ctx.NewErrorf(nil, "rem: %q", p.Rem(80))
+		p.expect(action{builtin: "<eof>"})
+		err := p.NewErrorf("unparsed: %q", p.Rem(80))
+		err.Kind = Unparsed
+		return out, err.finish(*p.stats)
 	}
 	return out, nil
 }
@@ -166,6 +316,7 @@ func (this *Prod) build(term string) (int, error) {
 	}
 
 	negative := false
+	ahead := false
 	silent := false
 	d := this.Directive
 	for {
@@ -194,6 +345,10 @@ func (this *Prod) build(term string) (int, error) {
 			negative = true
 			d = d[1:]
 
+		case '&': // positive look ahead
+			ahead = true
+			d = d[1:]
+
 		case '+': // commit to this production
 			this.actions = append(this.actions, action{
 				p:      this,
@@ -202,24 +357,114 @@ func (this *Prod) build(term string) (int, error) {
 			})
 			d = d[1:]
 
-		case '[': // TODO
-			re := regexp.MustCompile(`\[(.*)\]`)
-			m := re.FindString(d)
-			panic(m[1])
+		case '[': // optional group, matches zero or one time
+			if negative || ahead {
+				return 0, ctx.NewErrorf(nil, "can't do a lookahead with an optional group")
+			}
+			d = strings.TrimLeft(d[1:], " \t\n\r")
+
+			// internal name for the new alternation
+			optName := fmt.Sprintf("%s,opt%d", this.Name, this.g.repCt.Add(1))
+
+			// the group itself
+			p1 := &Prod{
+				g:         this.g,
+				Name:      optName,
+				Directive: d,
+				src:       this.src,
+				wsFrom:    this,
+			}
+			ct, err := p1.build("]")
+			if err != nil {
+				return len(this.Directive) - len(d), ctx.NewErrorf(nil, "invalid optional group: %v", err)
+			}
+			if !strings.HasPrefix(d[ct:], "]") {
+				return len(this.Directive) - len(d), ctx.NewErrorf(nil, "invalid optional group: missing `]` in `%s`", d)
+			}
+			p1.Directive = strings.TrimSpace(d[0:ct])
+			d = d[ct+1:]
+
+			// empty fallback, when the group doesn't match
+			p2 := &Prod{
+				g:         this.g,
+				Directive: "",
+				Name:      optName,
+				src:       this.src,
+				wsFrom:    this,
+			}
+			p2.mustBuild("")
+			p1.unret = func(v any) ([]any, error) {
+				if v == nil {
+					return nil, ctx.NewErrorf(nil, "empty")
+				}
+				return p1.defaultUnreturn(v)
+			}
+			p2.unret = func(v any) ([]any, error) {
+				if v != nil {
+					return nil, ctx.NewErrorf(nil, "not empty")
+				}
+				return nil, nil
+			}
+			this.g.Alt(optName).prods = []*Prod{p1, p2}
+			this.g.Alt(optName).internal = "opt"
+
+			this.actions = append(this.actions, action{
+				p:      this,
+				prod:   optName,
+				silent: silent,
+			})
+			negative = false
+			ahead = false
+			silent = false
+
+		case '<': // builtin directives, like `<eof>`
+			m := regexp.MustCompile(`^<(\w+)(?::([^>]*))?>`).FindStringSubmatch(d)
+			if m == nil {
+				return len(this.Directive) - len(d), ctx.NewErrorf(nil, "invalid directive: %q", d)
+			}
+			newCheck := builtins[m[1]]
+			if newCheck == nil {
+				return len(this.Directive) - len(d), ctx.NewErrorf(nil, "unknown directive `%s`", m[0])
+			}
+			check, err := newCheck(this, m[2])
+			if err != nil {
+				return len(this.Directive) - len(d), ctx.NewErrorf(nil, "invalid directive `%s`: %v", m[0], err)
+			}
+			d = d[len(m[0]):]
+			var message string
+			if m[1] == "error" {
+				message = m[2]
+			}
+			this.actions = append(this.actions, action{
+				p:        this,
+				builtin:  m[0],
+				check:    check,
+				message:  message,
+				negative: negative,
+				ahead:    ahead,
+				silent:   true,
+			})
+			negative = false
+			ahead = false
+			silent = false
 
 		case '"':
 			re, ct, err := parseText(d)
 			if err != nil {
 				return len(this.Directive) - len(d), nil
 			}
+			lit := d[1 : ct-1]
 			d = d[ct:]
 			this.actions = append(this.actions, action{
 				p:        this,
 				re:       re,
+				lit:      lit,
 				negative: negative,
+				ahead:    ahead,
 				silent:   true,
 			})
 			negative = false
+			ahead = false
 			silent = false
 
 		case '/':
@@ -233,9 +478,11 @@ func (this *Prod) build(term string) (int, error) {
 				p:        this,
 				re:       re,
 				negative: negative,
+				ahead:    ahead,
 				silent:   silent,
 			})
 			negative = false
+			ahead = false
 			silent = false
 
 		case ' ', '\t', '\n', '\r': // ignore whitespace
@@ -255,11 +502,15 @@ func (this *Prod) build(term string) (int, error) {
 				switch d[0:1] {
 				case "(":
 					d = d[1:]
-					if negative {
-						return 0, ctx.NewErrorf(nil, "can't do a negative lookahead with repetition")
+					if negative || ahead {
+						return 0, ctx.NewErrorf(nil, "can't do a lookahead with repetition")
 					}
 					temp := &Prod{
+						g:         this.g, // the separator can have optional groups
+						Name:      this.Name,
 						Directive: d,
+						src:       this.src,
+						wsFrom:    this,
 					}
 					ct, err := temp.build(")")
 					if err != nil {
@@ -302,6 +553,7 @@ func (this *Prod) build(term string) (int, error) {
 					p1.Return(func(l any, r []any) []any {
 						return append([]any{l}, r...)
 					})
+					p1.unret = unreturnList(1)
 
 					// internal prod, catches `sep` and `name` and then itself
 					p2 := &Prod{
@@ -323,10 +575,12 @@ func (this *Prod) build(term string) (int, error) {
 						p2.Return(func(sep, l any, r []any) []any {
 							return append([]any{sep, l}, r...)
 						})
+						p2.unret = unreturnList(2)
 					} else {
 						p2.Return(func(l any, r []any) []any {
 							return append([]any{l}, r...)
 						})
+						p2.unret = unreturnList(1)
 					}
 
 					// empty fallback, when reaching the end
@@ -339,8 +593,11 @@ func (this *Prod) build(term string) (int, error) {
 					}
 					p3.mustBuild("")
 					p3.Return(func() []any { return []any{} })
+					p3.unret = unreturnList(0)
 					this.g.Alt(repName).prods = []*Prod{p1}
+					this.g.Alt(repName).internal = "rep"
 					this.g.Alt(repRep).prods = []*Prod{p2, p3}
+					this.g.Alt(repRep).internal = "rep_"
 
 					name = repName // replace name with repName to use the above
 
@@ -351,9 +608,11 @@ func (this *Prod) build(term string) (int, error) {
 				p:        this,
 				prod:     name,
 				negative: negative,
+				ahead:    ahead,
 				silent:   silent,
 			})
 			negative = false
+			ahead = false
 			silent = false
 		}
 	}
@@ -363,6 +622,9 @@ func (this *Prod) verify() error {
 	for _, act := range this.actions {
 		if act.prod != "" {
 			alt := this.g.alts[act.prod]
+			if (alt == nil || len(alt.prods) == 0) && this.g.Lexer.Has(act.prod) {
+				continue // token kind
+			}
 			if alt == nil || len(alt.prods) == 0 {
 				return ctx.NewErrorf(nil, "production %q `%s` refers to empty %q", this.Name, this.Directive, act.prod)
 			}
@@ -384,7 +646,8 @@ func (this *Prod) exec(p *pos) (any, *Error) {
 	list := make([]any, 0, len(this.actions))
 	var err *Error
 	from := p.at
-	for _, act := range this.actions {
+	for i, act := range this.actions {
+		at := p.at
 		//if act.negative {
 		//	rev := p.at
 		//	_, err := act.exec(p)
@@ -396,14 +659,26 @@ func (this *Prod) exec(p *pos) (any, *Error) {
 		//} else {
 		out, err := act.exec(p)
 		if err != nil {
+			if this.expect != "" && act.prod == "" && !err.custom {
+				e := *err
+				e.Err = fmt.Errorf("expected %s got %q", this.expect, p.src.rem(err.Pos.End, 80))
+				e.custom = true
+				err = &e
+			}
 			if err.commit {
 				// committed error must return directly
 				return nil, err
 			}
-			if p.commit {
+			switch {
+			case p.commit && err.custom:
+				e := *err
+				e.commit = true
+				err = &e
+			case p.commit:
 				// if we are committed, but the error isn't, wrap it so it's easier to see where the commit happened
-				err = p.NewErrorf("expected %s got %q", act.String(), p.Rem(10))
+				err = p.NewErrorf("expected %s got %q", this.expected(act), p.Rem(10))
 				err.commit = true // and commit!
+				err.custom = this.expect != ""
 			}
 			// return a non-committed error
 			return nil, err
@@ -411,6 +686,11 @@ func (this *Prod) exec(p *pos) (any, *Error) {
 		if !act.silent {
 			list = append(list, out)
 		}
+		if p.g.Tracer != nil {
+			e := this.event(MatchItem, at, p.at)
+			e.Item, e.Text = i, act.item()
+			p.trace(e)
+		}
 		//}
 	}
 	if this.ret == nil {
@@ -425,8 +705,15 @@ func (this *Prod) exec(p *pos) (any, *Error) {
 	} else {
 		// if this.G.Log != nil { this.G.Log("ret(%v, %v)", in, out) }
 		out, err := this.ret(from, p, list)
+		if p.g.Tracer != nil {
+			e := this.event(ActionCall, from, p.at)
+			e.Args, e.Result, e.Err = list, out, err
+			p.trace(e)
+		}
 		if err != nil {
-			return out, &Error{err, p.at, p.commit}
+			e := p.newError(err, from)
+			e.Kind = ActionFailed
+			return out, e
 		}
 		p.Log("return %v", out)
 		return out, nil
@@ -436,6 +723,7 @@ func (this *Prod) exec(p *pos) (any, *Error) {
 // set a new return
 func (this *Prod) Return(action any) *Prod {
 	if action == nil {
+		this.retSig = nil
 		this.ret = func(from int, p *pos, in []any) (any, error) {
 			switch len(in) {
 			case 0:
@@ -450,6 +738,7 @@ func (this *Prod) Return(action any) *Prod {
 	}
 	f := reflect.ValueOf(action)
 	t := f.Type()
+	this.retSig = t
 
 	actNum := 0
 	for _, act := range this.actions {
@@ -468,11 +757,13 @@ func (this *Prod) Return(action any) *Prod {
 	switch t.NumOut() {
 	case 1:
 		this.retType = t.Out(0)
+		this.retErr = false
 	case 2:
 		if t.Out(1) != reflect.TypeOf((*error)(nil)).Elem() {
 			panic(fmt.Sprintf("%s: %v should return (X, error) or (X)", this.src, t))
 		}
 		this.retType = t.Out(0)
+		this.retErr = true
 	default:
 		panic(fmt.Sprintf("%s: %v should return (X, error) or (X)", this.src, t))
 	}
@@ -533,3 +824,123 @@ func (this *Prod) Return(action any) *Prod {
 	}
 	return this
 }
+
+// set the opposite of Return(), used by the Printer to split a value back into the directive items
+// the action must be in the form of `func(v X) (A, B, C...)`, optionally returning an error as last value
+// if the value is not assignable to X, or an error is returned, the Printer tries the next production
+func (this *Prod) Unreturn(action any) *Prod {
+	f := reflect.ValueOf(action)
+	t := f.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 {
+		panic(fmt.Sprintf("%s: %v should be in the form of func(X) (...)", this.src, t))
+	}
+	outNum := t.NumOut()
+	withErr := outNum > 0 && t.Out(outNum-1) == reflect.TypeOf((*error)(nil)).Elem()
+	if withErr {
+		outNum--
+	}
+	if outNum != this.items() {
+		panic(fmt.Sprintf("%s: %v returns %d values, but %d are in the directive", this.src, t, outNum, this.items()))
+	}
+	in := t.In(0)
+	this.unret = func(v any) ([]any, error) {
+		var arg reflect.Value
+		switch {
+		case v == nil:
+			switch in.Kind() {
+			case reflect.Interface, reflect.Slice, reflect.Pointer, reflect.Map:
+				arg = reflect.New(in).Elem()
+			default:
+				return nil, ctx.NewErrorf(nil, "nil is not %v", in)
+			}
+		case reflect.TypeOf(v).AssignableTo(in):
+			arg = reflect.ValueOf(v)
+		case in.Kind() == reflect.Slice && reflect.TypeOf(v).Kind() == reflect.Slice:
+			var err error
+			arg, err = coerce(reflect.ValueOf(v), in)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, ctx.NewErrorf(nil, "%T is not %v", v, in)
+		}
+		out := f.Call([]reflect.Value{arg})
+		if withErr {
+			if err, _ := out[outNum].Interface().(error); err != nil {
+				return nil, err
+			}
+			out = out[0:outNum]
+		}
+		var list []any
+		for _, o := range out {
+			list = append(list, o.Interface())
+		}
+		return list, nil
+	}
+	return this
+}
+
+// describe what the production expects (e.g. "a closing parenthesis"), the errors of its terminals and
+// builtins, and the error when it fails after a commit, say `expected <what>` instead of the item which failed
+// the errors of the sub rules are kept, use Alts.Label() for those
+func (this *Prod) Expect(what string) *Prod {
+	this.expect = what
+	return this
+}
+
+// the failed item, as said in the errors
+func (this *Prod) expected(act action) string {
+	if this.expect != "" {
+		return this.expect
+	}
+	return act.String()
+}
+
+// set the highlight class (e.g. "keyword", "string") of the text matched by this production, which applies to
+// all its terminals, unless a sub production or the terminal has its own class, see Grammar.Highlight()
+func (this *Prod) Class(name string) *Prod {
+	this.class = name
+	return this
+}
+
+// set the highlight class of the terminals of this production written as term in the directive (e.g. `"let"`,
+// `/\d+/` or a token kind), which wins over the class of the productions
+// panics if there is no such terminal
+func (this *Prod) TermClass(term, name string) *Prod {
+	if !this.termClass(term, name) {
+		panic(fmt.Sprintf("%s: no terminal %s in `%s`", this.src, term, this.Directive))
+	}
+	return this
+}
+
+func (this *Prod) termClass(term, name string) bool {
+	found := false
+	for i, act := range this.actions {
+		if alt := this.g.alts[act.prod]; alt != nil && alt.internal != "" {
+			// the optional groups and the separators of the repetitions
+			for _, p := range alt.prods {
+				found = p.termClass(term, name) || found
+			}
+			continue
+		}
+		if act.negative || act.ahead || act.builtin != "" || act.expected() != term {
+			continue
+		}
+		if act.lit != "" || act.re != nil || this.g.Lexer.Has(act.prod) {
+			this.actions[i].class = name
+			found = true
+		}
+	}
+	return found
+}
+
+// number of items which are not silent
+func (this *Prod) items() int {
+	ct := 0
+	for _, act := range this.actions {
+		if !act.silent {
+			ct++
+		}
+	}
+	return ct
+}
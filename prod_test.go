@@ -2,6 +2,7 @@ package parse
 
 import (
 	"fmt"
+	"regexp"
 	"testing"
 
 	"github.com/ohait/forego/test"
@@ -239,3 +240,27 @@ func TestAssocSep(t *testing.T) {
 	test.NoError(t, err)
 	test.EqualsJSON(t, `["adam","john","luke"]`, out)
 }
+
+func TestExpected(t *testing.T) {
+	var g Grammar
+	ws := regexp.MustCompile(`^\s*`)
+	g.Add("list", `"[" [ item(s ",") ] "]"`).WS = ws
+	g.Add("item", `!"x" /\d+/`).WS = ws
+	g.Add("item", `"nil"`).WS = ws
+	test.NoError(t, g.Verify())
+
+	_, s, err := g.Parse("list", []byte("[1, 2 3]"))
+	test.Error(t, err)
+	test.EqualsGo(t, 6, s.Farthest)
+	test.EqualsGo(t, []string{`","`, `"]"`}, s.Expected)
+
+	_, s, err = g.Parse("list", []byte("[1, -]"))
+	test.Error(t, err)
+	test.EqualsGo(t, 4, s.Farthest)
+	test.EqualsGo(t, []string{`/\d+/`, `"nil"`}, s.Expected) // the negative lookahead is not listed
+
+	_, s, err = g.Parse("list", []byte("[] 1"))
+	test.Error(t, err)
+	test.EqualsGo(t, 2, s.Farthest)
+	test.EqualsGo(t, []string{"<eof>"}, s.Expected)
+}
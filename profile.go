@@ -0,0 +1,269 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// collect per rule and per alternative statistics, set it as the Grammar.Tracer (or in a MultiTracer):
+//
+//	prof := &parse.Profiler{}
+//	g.Tracer = prof
+//	... parse ...
+//	fmt.Print(prof.Report().SortBy("self"))
+//	prof.WritePprof(f) // go tool pprof -top f
+//
+// the events are expected in order, so don't use the grammar concurrently while profiling
+type Profiler struct {
+	mu      sync.Mutex
+	entries map[profKey]*ProfileEntry
+	stack   []*profFrame
+	samples map[string]*profSample
+	funcs   map[profKey]uint64 // the pprof function id of each alternative
+	start   time.Time
+	end     time.Time
+}
+
+type profKey struct {
+	rule string
+	alt  int
+}
+
+// a rule being parsed
+type profFrame struct {
+	rule  string
+	start time.Time
+	child time.Duration // time spent in the sub rules
+
+	alt      *ProfileEntry // the alternative being tried, if any
+	altStart time.Time
+	altChild time.Duration
+}
+
+type profSample struct {
+	stack       []uint64 // leaf first
+	attempts    int64
+	backtracked int64
+	self        time.Duration
+}
+
+// the statistics of a rule, or of one of its alternatives
+type ProfileEntry struct {
+	Rule      string
+	Alt       int    // the index of the alternative, -1 for the whole rule
+	Src       string // file:line where the alternative was added
+	Directive string
+
+	Attempts    int
+	Successes   int
+	Failures    int
+	Consumed    int           // bytes matched when successful
+	Backtracked int           // bytes given back when failing
+	Total       time.Duration // cumulative time, including the sub rules (recursive rules are counted more than once)
+	Self        time.Duration // excluding the sub rules
+}
+
+func (this *Profiler) Trace(e Event) {
+	now := time.Now()
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.entries == nil {
+		this.entries = map[profKey]*ProfileEntry{}
+		this.samples = map[string]*profSample{}
+		this.funcs = map[profKey]uint64{}
+		this.start = now
+	}
+	this.end = now
+
+	var top *profFrame
+	if len(this.stack) > 0 {
+		top = this.stack[len(this.stack)-1]
+	}
+	switch e.Kind {
+	case EnterRule:
+		this.stack = append(this.stack, &profFrame{rule: e.Rule, start: now})
+	case TryAlt:
+		if top == nil {
+			return
+		}
+		top.alt = this.entry(e.Rule, e.Alt)
+		top.alt.Src = e.Src
+		top.alt.Directive = e.Directive
+		top.alt.Attempts++
+		top.altStart = now
+		top.altChild = 0
+	case Backtrack:
+		if top == nil || top.alt == nil {
+			return
+		}
+		this.closeAlt(top, now, false, 0, e.Pos.End-e.Pos.From)
+	case ExitRule:
+		if top == nil {
+			return
+		}
+		consumed, backtracked := 0, 0
+		if e.Err == nil {
+			consumed = e.Pos.End - e.Pos.From
+		} else {
+			backtracked = e.Pos.End - e.Pos.From
+		}
+		if top.alt != nil {
+			// the last alternative, not followed by a Backtrack
+			this.closeAlt(top, now, e.Err == nil, consumed, backtracked)
+		}
+		r := this.entry(top.rule, -1)
+		r.Attempts++
+		if e.Err == nil {
+			r.Successes++
+		} else {
+			r.Failures++
+		}
+		r.Consumed += consumed
+		r.Backtracked += backtracked
+		total := now.Sub(top.start)
+		r.Total += total
+		r.Self += total - top.child
+
+		this.stack = this.stack[:len(this.stack)-1]
+		if len(this.stack) > 0 {
+			parent := this.stack[len(this.stack)-1]
+			parent.child += total
+			parent.altChild += total
+		}
+	}
+}
+
+// the alternative being tried in the frame has finished
+func (this *Profiler) closeAlt(f *profFrame, now time.Time, ok bool, consumed, backtracked int) {
+	a := f.alt
+	f.alt = nil
+	if ok {
+		a.Successes++
+	} else {
+		a.Failures++
+	}
+	a.Consumed += consumed
+	a.Backtracked += backtracked
+	total := now.Sub(f.altStart)
+	a.Total += total
+	a.Self += total - f.altChild
+
+	// the sample for pprof is the stack of the alternatives being tried
+	var stack []uint64
+	var key strings.Builder
+	stack = append(stack, this.funcID(a))
+	for i := len(this.stack) - 2; i >= 0; i-- {
+		if p := this.stack[i].alt; p != nil {
+			stack = append(stack, this.funcID(p))
+		}
+	}
+	for _, id := range stack {
+		fmt.Fprintf(&key, "%d,", id)
+	}
+	s := this.samples[key.String()]
+	if s == nil {
+		s = &profSample{stack: stack}
+		this.samples[key.String()] = s
+	}
+	s.attempts++
+	s.backtracked += int64(backtracked)
+	s.self += total - f.altChild
+}
+
+func (this *Profiler) entry(rule string, alt int) *ProfileEntry {
+	k := profKey{rule, alt}
+	e := this.entries[k]
+	if e == nil {
+		e = &ProfileEntry{Rule: rule, Alt: alt}
+		this.entries[k] = e
+	}
+	return e
+}
+
+func (this *Profiler) funcID(e *ProfileEntry) uint64 {
+	k := profKey{e.Rule, e.Alt}
+	id := this.funcs[k]
+	if id == 0 {
+		id = uint64(len(this.funcs) + 1)
+		this.funcs[k] = id
+	}
+	return id
+}
+
+// forget what was collected so far
+func (this *Profiler) Reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.entries = nil
+	this.stack = nil
+	this.samples = nil
+	this.funcs = nil
+}
+
+// the statistics collected so far, sorted by rule and alternative
+func (this *Profiler) Report() ProfileReport {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	out := make(ProfileReport, 0, len(this.entries))
+	for _, e := range this.entries {
+		out = append(out, *e)
+	}
+	out.SortBy("rule")
+	return out
+}
+
+type ProfileReport []ProfileEntry
+
+// sort the report, by "rule" (then alternative), or descending by "attempts", "failures",
+// "consumed", "backtracked", "total" or "self"
+func (this ProfileReport) SortBy(key string) ProfileReport {
+	var less func(a, b ProfileEntry) bool
+	switch key {
+	case "rule":
+		less = func(a, b ProfileEntry) bool {
+			if a.Rule != b.Rule {
+				return a.Rule < b.Rule
+			}
+			return a.Alt < b.Alt
+		}
+	case "attempts":
+		less = func(a, b ProfileEntry) bool { return a.Attempts > b.Attempts }
+	case "failures":
+		less = func(a, b ProfileEntry) bool { return a.Failures > b.Failures }
+	case "consumed":
+		less = func(a, b ProfileEntry) bool { return a.Consumed > b.Consumed }
+	case "backtracked":
+		less = func(a, b ProfileEntry) bool { return a.Backtracked > b.Backtracked }
+	case "total":
+		less = func(a, b ProfileEntry) bool { return a.Total > b.Total }
+	case "self":
+		less = func(a, b ProfileEntry) bool { return a.Self > b.Self }
+	default:
+		panic(fmt.Sprintf("can't sort a profile by %q", key))
+	}
+	sort.SliceStable(this, func(i, j int) bool {
+		return less(this[i], this[j])
+	})
+	return this
+}
+
+// the report as a table
+func (this ProfileReport) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "attempts\tok\tfailed\tconsumed\tbacktracked\ttotal\tself\t\n")
+	for _, e := range this {
+		name := e.Rule
+		if e.Alt >= 0 {
+			name = fmt.Sprintf("  %s/%d `%s`", e.Rule, e.Alt, e.Directive)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%v\t%v\t %s\n",
+			e.Attempts, e.Successes, e.Failures, e.Consumed, e.Backtracked, e.Total, e.Self, name)
+	}
+	w.Flush()
+	return sb.String()
+}
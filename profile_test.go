@@ -0,0 +1,59 @@
+package parse_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func TestProfiler(t *testing.T) {
+	g := tracerGrammar()
+	prof := &parse.Profiler{}
+	var ct int
+	g.Tracer = parse.MultiTracer{prof, parse.TracerFunc(func(parse.Event) { ct++ })}
+	for _, in := range []string{"(1,2)", "3", "(4,5)", "(6"} {
+		g.Parse("pair", []byte(in))
+	}
+	test.Assert(t, ct > 0)
+	r := prof.Report()
+	t.Logf("\n%s", r)
+
+	type counts struct {
+		Rule                                                 string
+		Alt                                                  int
+		Attempts, Successes, Failures, Consumed, Backtracked int
+	}
+	var got []counts
+	for _, e := range r {
+		got = append(got, counts{e.Rule, e.Alt, e.Attempts, e.Successes, e.Failures, e.Consumed, e.Backtracked})
+	}
+	test.EqualsGo(t, []counts{
+		{"num", -1, 6, 6, 0, 6, 0},
+		{"num", 0, 6, 6, 0, 6, 0},
+		{"pair", -1, 4, 3, 1, 11, 2},
+		{"pair", 0, 4, 2, 2, 10, 2}, // `3` backtracks, `(6` fails after the commit
+		{"pair", 1, 1, 1, 0, 1, 0},
+	}, got)
+
+	r.SortBy("attempts")
+	test.EqualsGo(t, "num", r[0].Rule)
+	for _, e := range r {
+		test.Assert(t, e.Total >= e.Self)
+	}
+
+	var buf bytes.Buffer
+	test.NoError(t, prof.WritePprof(&buf))
+	z, err := gzip.NewReader(&buf)
+	test.NoError(t, err)
+	raw, err := io.ReadAll(z)
+	test.NoError(t, err)
+	test.Contains(t, string(raw), "pair/0")
+	test.Contains(t, string(raw), "tracer_test.go")
+
+	prof.Reset()
+	test.EqualsGo(t, 0, len(prof.Report()))
+}
@@ -0,0 +1,215 @@
+package parse
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// export the grammar as a standalone HTML page, with an SVG railroad diagram for each rule
+func (this *Grammar) Railroad() string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>grammar</title>
+<style>
+body { font-family: sans-serif; }
+svg.railroad path { stroke: #333; stroke-width: 2; fill: none; }
+svg.railroad rect { stroke: #333; stroke-width: 2; }
+svg.railroad rect.terminal { fill: #ffc; }
+svg.railroad rect.rule { fill: #cdf; }
+svg.railroad rect.special { fill: #eee; stroke-dasharray: 4 2; }
+svg.railroad text { font-family: monospace; font-size: 13px; text-anchor: middle; dominant-baseline: central; }
+svg.railroad circle { fill: #333; }
+</style>
+</head>
+<body>
+`)
+	for _, name := range this.ruleNames() {
+		this.railroadRule(&b, name, this.ruleExpr(name))
+	}
+	if this.Lexer != nil {
+		for _, r := range this.Lexer.rules {
+			this.railroadRule(&b, r.kind, reExpr(r.re))
+		}
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+func (this *Grammar) railroadRule(b *strings.Builder, name string, e *expr) {
+	d := rrExpr(e)
+	const pad = 20
+	w, h := d.w+2*pad, d.h+2*pad
+	fmt.Fprintf(b, "<h2 id=%q>%s</h2>\n", "rule-"+name, html.EscapeString(name))
+	fmt.Fprintf(b, "<svg class=\"railroad\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", w, h, w, h)
+	y := pad + d.y0
+	fmt.Fprintf(b, "<circle cx=\"5\" cy=\"%d\" r=\"5\"/><path d=\"M5 %d H%d\"/>\n", y, y, pad)
+	d.draw(b, pad, pad)
+	fmt.Fprintf(b, "<path d=\"M%d %d H%d\"/><circle cx=\"%d\" cy=\"%d\" r=\"5\"/>\n", pad+d.w, y, w-5, w-5, y)
+	b.WriteString("</svg>\n")
+}
+
+// a piece of diagram, the line enters on the left and exits on the right at y0
+type rr struct {
+	w, h, y0 int
+	draw     func(b *strings.Builder, x, y int)
+}
+
+const (
+	rrGap  = 10
+	rrRail = 20
+)
+
+func rrExpr(e *expr) rr {
+	switch e.op {
+	case exprSeq:
+		var list []rr
+		for _, s := range e.sub {
+			list = append(list, rrExpr(s))
+		}
+		return rrSeq(list)
+	case exprChoice:
+		var list []rr
+		for _, s := range e.sub {
+			list = append(list, rrExpr(s))
+		}
+		return rrChoice(list)
+	case exprLit:
+		return rrBox(`"`+e.text+`"`, "terminal", "")
+	case exprClass:
+		return rrBox(e.text, "terminal", "")
+	case exprRE:
+		return rrBox("/"+e.text+"/", "terminal", "")
+	case exprRef, exprKind:
+		return rrBox(e.text, "rule", "#rule-"+e.text)
+	case exprBuiltin:
+		return rrBox(e.text, "special", "")
+	case exprNot:
+		return rrBox("not "+ebnf(e.sub[0], 2), "special", "")
+	case exprAnd:
+		return rrBox("followed by "+ebnf(e.sub[0], 2), "special", "")
+	case exprOpt:
+		return rrChoice([]rr{rrEmpty(), rrExpr(e.sub[0])})
+	case exprPlus:
+		return rrLoop(rrExpr(e.sub[0]))
+	case exprStar:
+		return rrChoice([]rr{rrEmpty(), rrLoop(rrExpr(e.sub[0]))})
+	}
+	return rrEmpty()
+}
+
+func rrEmpty() rr {
+	return rr{draw: func(b *strings.Builder, x, y int) {}}
+}
+
+func rrBox(text, class, href string) rr {
+	w := 7*len([]rune(text)) + 20
+	return rr{
+		w: w, h: 24, y0: 12,
+		draw: func(b *strings.Builder, x, y int) {
+			if href != "" {
+				fmt.Fprintf(b, "<a href=%q>", href)
+			}
+			r := 0
+			if class == "terminal" {
+				r = 10
+			}
+			fmt.Fprintf(b, "<rect class=%q x=\"%d\" y=\"%d\" width=\"%d\" height=\"24\" rx=\"%d\"/>", class, x, y, w, r)
+			fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\">%s</text>", x+w/2, y+12, html.EscapeString(text))
+			if href != "" {
+				b.WriteString("</a>")
+			}
+			b.WriteString("\n")
+		},
+	}
+}
+
+func rrSeq(list []rr) rr {
+	out := rr{}
+	down := 0
+	for i, d := range list {
+		if i > 0 {
+			out.w += rrGap
+		}
+		out.w += d.w
+		out.y0 = max(out.y0, d.y0)
+		down = max(down, d.h-d.y0)
+	}
+	out.h = out.y0 + down
+	out.draw = func(b *strings.Builder, x, y int) {
+		for i, d := range list {
+			if i > 0 {
+				fmt.Fprintf(b, "<path d=\"M%d %d h%d\"/>\n", x, y+out.y0, rrGap)
+				x += rrGap
+			}
+			d.draw(b, x, y+out.y0-d.y0)
+			x += d.w
+		}
+	}
+	return out
+}
+
+// the first branch is on the main line, the others below
+func rrChoice(list []rr) rr {
+	out := rr{y0: list[0].y0}
+	inner := 0
+	for i, d := range list {
+		inner = max(inner, d.w)
+		if i > 0 {
+			out.h += rrGap
+		}
+		out.h += d.h
+	}
+	out.w = inner + 2*rrRail
+	out.draw = func(b *strings.Builder, x, y int) {
+		main := y + out.y0
+		top := y
+		for i, d := range list {
+			if i > 0 {
+				top += rrGap
+			}
+			line := top + d.y0
+			left := x + rrRail
+			right := left + d.w
+			if i == 0 {
+				fmt.Fprintf(b, "<path d=\"M%d %d H%d\"/>\n", x, main, left)
+				fmt.Fprintf(b, "<path d=\"M%d %d H%d\"/>\n", right, main, x+out.w)
+			} else {
+				fmt.Fprintf(b, "<path d=\"M%d %d q%d 0 %d %d V%d q0 %d %d %d H%d\"/>\n",
+					x, main, rrRail/2, rrRail/2, rrRail/2, line-rrRail/2, rrRail/2, rrRail/2, rrRail/2, left)
+				fmt.Fprintf(b, "<path d=\"M%d %d H%d q%d 0 %d %d V%d q0 %d %d %d\"/>\n",
+					right, line, x+out.w-rrRail, rrRail/2, rrRail/2, -rrRail/2, main+rrRail/2, -rrRail/2, rrRail/2, -rrRail/2)
+			}
+			if d.w < inner {
+				fmt.Fprintf(b, "<path d=\"M%d %d H%d\"/>\n", right, line, left+inner)
+			}
+			d.draw(b, left, top)
+			top += d.h
+		}
+	}
+	return out
+}
+
+// one or more, the loop goes back below the item
+func rrLoop(item rr) rr {
+	out := rr{
+		w:  item.w + 2*rrRail,
+		y0: item.y0,
+		h:  item.h + rrGap + rrRail/2,
+	}
+	out.draw = func(b *strings.Builder, x, y int) {
+		main := y + out.y0
+		back := y + item.h + rrGap
+		left, right := x+rrRail, x+rrRail+item.w
+		fmt.Fprintf(b, "<path d=\"M%d %d H%d\"/>\n", x, main, left)
+		fmt.Fprintf(b, "<path d=\"M%d %d H%d\"/>\n", right, main, x+out.w)
+		fmt.Fprintf(b, "<path d=\"M%d %d q%d 0 %d %d V%d q0 %d %d %d H%d q%d 0 %d %d V%d q0 %d %d %d\"/>\n",
+			right, main, rrRail/2, rrRail/2, rrRail/2, back-rrRail/2, rrRail/2, -rrRail/2, rrRail/2, left,
+			-rrRail/2, -rrRail/2, -rrRail/2, main+rrRail/2, -rrRail/2, rrRail/2, -rrRail/2)
+		item.draw(b, left, y)
+	}
+	return out
+}
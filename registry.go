@@ -0,0 +1,45 @@
+package parse
+
+import (
+	"sort"
+	"sync"
+)
+
+var registry = struct {
+	sync.Mutex
+	builders map[string]func() *Grammar
+}{builders: map[string]func() *Grammar{}}
+
+// make a grammar available by name to tools like cmd/prd and cmd/prdgen, usually from an init()
+// panics if the name is already taken
+func Register(name string, build func() *Grammar) {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.builders[name] != nil {
+		panic("grammar " + name + " registered twice")
+	}
+	registry.builders[name] = build
+}
+
+// build the grammar registered with the given name, or return nil
+func Registered(name string) *Grammar {
+	registry.Lock()
+	build := registry.builders[name]
+	registry.Unlock()
+	if build == nil {
+		return nil
+	}
+	return build()
+}
+
+// the names of the registered grammars, sorted
+func RegisteredNames() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	var list []string
+	for name := range registry.builders {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -7,10 +7,7 @@ type Src struct {
 	linesLength []int
 }
 
-func (this *Src) Line(offset int) int {
-	if this == nil {
-		return 0
-	}
+func (this *Src) lines() []int {
 	if this.linesLength == nil {
 		lines := bytes.Split(this.bytes, []byte{'\n'})
 		lengths := make([]int, len(lines))
@@ -22,13 +19,52 @@ func (this *Src) Line(offset int) int {
 		}
 		this.linesLength = lengths
 	}
+	return this.linesLength
+}
+
+// up to max bytes of text from the given offset
+func (this *Src) rem(at, max int) string {
+	rem := this.bytes[at:]
+	if len(rem) > max {
+		rem = rem[0:max]
+	}
+	return string(rem)
+}
+
+func (this *Src) Line(offset int) int {
+	line, _ := this.LineCol(offset)
+	return line
+}
+
+// 1-based column (in bytes) of the given offset
+func (this *Src) Col(offset int) int {
+	_, col := this.LineCol(offset)
+	return col
+}
+
+// 1-based line and column (in bytes) of the given offset
+func (this *Src) LineCol(offset int) (int, int) {
+	if this == nil {
+		return 0, 0
+	}
+	lines := this.lines()
 	line := 1
-	for _, l := range this.linesLength {
+	for _, l := range lines {
 		if offset < l {
-			return line
+			return line, offset + 1
 		}
 		offset -= l
 		line++
 	}
-	return len(this.linesLength) // clamp to last line
+	// clamp to last line
+	return len(lines), lines[len(lines)-1] + offset + 1
+}
+
+// offset where the given 1-based line starts
+func (this *Src) lineStart(line int) int {
+	at := 0
+	for _, l := range this.lines()[:line-1] {
+		at += l
+	}
+	return at
 }
@@ -41,3 +41,24 @@ func TestSrcLineTrailingNewline(t *testing.T) {
 		}
 	}
 }
+
+func TestSrcLineCol(t *testing.T) {
+	src := Src{bytes: []byte("ab\ncd\n")}
+	tests := []struct {
+		off  int
+		line int
+		col  int
+	}{
+		{0, 1, 1},
+		{1, 1, 2},
+		{2, 1, 3},
+		{3, 2, 1},
+		{5, 2, 3},
+		{6, 3, 1},
+	}
+	for _, tt := range tests {
+		if line, col := src.LineCol(tt.off); line != tt.line || col != tt.col {
+			t.Errorf("offset %d: expected %d:%d got %d:%d", tt.off, tt.line, tt.col, line, col)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var suggestWord = regexp.MustCompile(`^[\p{L}\p{N}_]+`)
+
+// the keywords expected at the farthest position which are close to the word found there
+func suggestions(src *Src, s Stats) []string {
+	if src == nil || s.Farthest < 0 || s.Farthest > len(src.bytes) {
+		return nil
+	}
+	word := string(suggestWord.Find(src.bytes[s.Farthest:]))
+	if word == "" {
+		return nil
+	}
+	type candidate struct {
+		kw   string
+		dist int
+	}
+	var list []candidate
+	for _, e := range s.Expected {
+		if len(e) < 2 || e[0] != '"' || e[len(e)-1] != '"' {
+			continue // only literals
+		}
+		kw := e[1 : len(e)-1]
+		if suggestWord.FindString(kw) != kw {
+			continue // not a keyword
+		}
+		d := editDistance(word, kw)
+		if d > 0 && d <= max(1, len([]rune(kw))/3) {
+			list = append(list, candidate{kw, d})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].dist < list[j].dist
+	})
+	var out []string
+	for _, c := range list[:min(3, len(list))] {
+		out = append(out, c.kw)
+	}
+	return out
+}
+
+// `did you mean "a" or "b"?`, or "" if there are no suggestions
+func (this Error) Hint() string {
+	if len(this.Suggestions) == 0 {
+		return ""
+	}
+	var quoted []string
+	for _, s := range this.Suggestions {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	return "did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
+// the edits (insertions, deletions, substitutions and swaps of adjacent runes) to turn a into b
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// rows i-2, i-1 and i of the distances between the prefixes
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(t)]
+}
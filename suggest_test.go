@@ -0,0 +1,68 @@
+package parse
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ohait/forego/test"
+)
+
+func TestSuggestions(t *testing.T) {
+	g, err := LoadGrammar("sql.prd", []byte(`%ws /\s*/
+stmt: cmd /[\w*]+/ "from" /\w+/ <eof>
+cmd: "select"
+   | "delete"
+   | "insert"
+`))
+	test.NoError(t, err)
+
+	var perr *Error
+	_, _, err = g.Parse("stmt", []byte(`selct * from t`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, []string{"select"}, perr.Suggestions)
+	test.Contains(t, err.Error(), `did you mean "select"?`)
+	test.EqualsGo(t, `did you mean "select"?`, perr.Hint())
+	test.Contains(t, perr.Pretty(), "\n did you mean \"select\"?")
+
+	// the closest first
+	_, _, err = g.Parse("stmt", []byte(`delect * from t`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, []string{"select", "delete"}, perr.Suggestions)
+	test.Contains(t, err.Error(), `did you mean "select" or "delete"?`)
+
+	// swapped letters are a single edit
+	_, _, err = g.Parse("stmt", []byte(`select * form t`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, []string{"from"}, perr.Suggestions)
+
+	// nothing close enough
+	_, _, err = g.Parse("stmt", []byte(`update t`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, 0, len(perr.Suggestions))
+	test.NotContains(t, err.Error(), "did you mean")
+
+	// not for symbols
+	g.Add("list", `"[" /\d+/ "]" <eof>`)
+	_, _, err = g.Parse("list", []byte(`[1 x`))
+	test.Assert(t, errors.As(err, &perr))
+	test.EqualsGo(t, 0, len(perr.Suggestions))
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"select", "select", 0},
+		{"selct", "select", 1},
+		{"form", "from", 1},
+		{"kitten", "sitting", 3},
+		{"naïve", "naive", 1},
+	} {
+		test.EqualsGo(t, c.d, editDistance(c.a, c.b))
+		test.EqualsGo(t, c.d, editDistance(c.b, c.a))
+	}
+}
@@ -0,0 +1,274 @@
+package parse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// what happened during the parse, see Event
+type EventKind int
+
+const (
+	EnterRule  EventKind = iota // about to try the alternatives of a rule
+	ExitRule                    // a rule matched (Result) or failed (Err)
+	TryAlt                      // about to try one of the alternatives
+	Consume                     // a terminal matched, Text is what was consumed
+	Backtrack                   // an alternative failed, Pos is the text given back
+	Commit                      // the current alternative committed, see `+`
+	ActionCall                  // the Return() function was called with Args
+	MatchItem                   // an item of the directive matched, Text is the item
+)
+
+func (this EventKind) String() string {
+	switch this {
+	case EnterRule:
+		return "enter"
+	case ExitRule:
+		return "exit"
+	case TryAlt:
+		return "try"
+	case Consume:
+		return "consume"
+	case Backtrack:
+		return "backtrack"
+	case Commit:
+		return "commit"
+	case ActionCall:
+		return "action"
+	case MatchItem:
+		return "item"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(this))
+}
+
+func (this EventKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.String())
+}
+
+// an event during the parse, sent to Grammar.Tracer
+type Event struct {
+	Kind  EventKind
+	Rule  string // the rule, internal ones are named like `rule,rep1`
+	Alt   int    // the index of the alternative, for ExitRule the one which matched (-1 if none)
+	Depth int    // how many rules are being parsed
+	Pos   Pos    // the text matched (or consumed, or backtracked), empty at the current position for the others
+
+	Src       string // file:line where the production was added
+	Item      int    // the index of the item in the directive, for MatchItem
+	Directive string // the production, for TryAlt, Backtrack, Commit, ActionCall and MatchItem
+	Text      string // the terminal matched for Consume, the item for MatchItem
+	Args      []any  // the items given to Return(), for ActionCall
+	Result    any    // the value produced, for ExitRule and ActionCall
+	Err       error  // why it failed, for ExitRule, Backtrack and ActionCall
+}
+
+func (this Event) String() string {
+	s := fmt.Sprintf("%s %s", this.Kind, this.Rule)
+	switch this.Kind {
+	case TryAlt, Backtrack, Commit, ActionCall:
+		s += fmt.Sprintf("/%d `%s`", this.Alt, this.Directive)
+	case MatchItem:
+		s += fmt.Sprintf("/%d #%d %s", this.Alt, this.Item, this.Text)
+	}
+	s += fmt.Sprintf(" @%d", this.Pos.From)
+	if this.Pos.End != this.Pos.From {
+		s += fmt.Sprintf("-%d", this.Pos.End)
+	}
+	switch this.Kind {
+	case Consume:
+		s += fmt.Sprintf(" %s %q", this.Text, this.Pos.Extract(0))
+	case ActionCall:
+		s += fmt.Sprintf(" %v", this.Args)
+	}
+	if this.Err != nil {
+		s += fmt.Sprintf(": %v", this.Err)
+	} else if this.Kind == ExitRule || this.Kind == ActionCall {
+		s += fmt.Sprintf(" => %v", this.Result)
+	}
+	return s
+}
+
+// the event as a JSON object, with the offsets of the position, and values which can't be encoded as strings
+func (this Event) MarshalJSON() ([]byte, error) {
+	out := map[string]any{
+		"kind":  this.Kind,
+		"rule":  this.Rule,
+		"depth": this.Depth,
+		"from":  this.Pos.From,
+		"end":   this.Pos.End,
+	}
+	if this.Pos.File != "" {
+		out["file"] = this.Pos.File
+	}
+	switch this.Kind {
+	case TryAlt, Backtrack, Commit, ActionCall:
+		out["alt"] = this.Alt
+		out["src"] = this.Src
+		out["directive"] = this.Directive
+	case MatchItem:
+		out["alt"] = this.Alt
+		out["item"] = this.Item
+		out["text"] = this.Text
+	case Consume:
+		out["text"] = this.Text
+		out["match"] = this.Pos.Extract(0)
+	}
+	if this.Args != nil {
+		args := make([]any, len(this.Args))
+		for i, a := range this.Args {
+			args[i] = jsonable(a)
+		}
+		out["args"] = args
+	}
+	if this.Err != nil {
+		out["error"] = this.Err.Error()
+	} else if this.Kind == ExitRule || this.Kind == ActionCall {
+		out["result"] = jsonable(this.Result)
+	}
+	return json.Marshal(out)
+}
+
+// the value if it can be encoded, or how it prints
+func jsonable(v any) any {
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return v
+}
+
+// receives the events of the parse, see Grammar.Tracer
+type Tracer interface {
+	Trace(Event)
+}
+
+// a function used as a Tracer
+type TracerFunc func(Event)
+
+func (this TracerFunc) Trace(e Event) { this(e) }
+
+// send the events to each of the tracers, in order
+type MultiTracer []Tracer
+
+func (this MultiTracer) Trace(e Event) {
+	for _, t := range this {
+		t.Trace(e)
+	}
+}
+
+// write the events as text, one per line, indented by depth
+type TextTracer struct {
+	W     io.Writer
+	Color bool // use ANSI colours, like the output of Grammar.Log
+
+	mu sync.Mutex
+}
+
+func (this *TextTracer) Trace(e Event) {
+	s := e.String()
+	if this.Color {
+		color := "0"
+		switch {
+		case e.Err != nil:
+			color = "0;31"
+		case e.Kind == Consume || e.Kind == ExitRule:
+			color = "0;32"
+		case e.Kind == Commit:
+			color = "0;35"
+		case e.Kind == TryAlt:
+			color = "0;34"
+		}
+		s = "\033[" + color + "m" + s + "\033[0m"
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	fmt.Fprintf(this.W, "%s%s\n", strings.Repeat(" ", e.Depth), s)
+}
+
+// write the events as JSON Lines
+type JSONTracer struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+func (this *JSONTracer) Trace(e Event) {
+	j, err := json.Marshal(e)
+	if err != nil {
+		j, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.W.Write(append(j, '\n'))
+}
+
+// log the events using log/slog, at the given level (usually slog.LevelDebug)
+type SlogTracer struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+func (this SlogTracer) Trace(e Event) {
+	l := this.Logger
+	if l == nil {
+		l = slog.Default()
+	}
+	c := context.Background()
+	if !l.Enabled(c, this.Level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("rule", e.Rule),
+		slog.Int("depth", e.Depth),
+		slog.Int("from", e.Pos.From),
+		slog.Int("end", e.Pos.End),
+	}
+	switch e.Kind {
+	case TryAlt, Backtrack, Commit, ActionCall:
+		attrs = append(attrs, slog.Int("alt", e.Alt), slog.String("directive", e.Directive))
+	case MatchItem:
+		attrs = append(attrs, slog.Int("alt", e.Alt), slog.Int("item", e.Item), slog.String("text", e.Text))
+	case Consume:
+		attrs = append(attrs, slog.String("text", e.Text), slog.String("match", e.Pos.Extract(0)))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	} else if e.Kind == ExitRule || e.Kind == ActionCall {
+		attrs = append(attrs, slog.Any("result", e.Result))
+	}
+	l.LogAttrs(c, this.Level, "parse "+e.Kind.String(), attrs...)
+}
+
+// send the event to the tracer, callers check Grammar.Tracer first to avoid creating the events
+func (this *pos) trace(e Event) {
+	e.Depth = len(this.stack)
+	e.Pos.File = this.file
+	e.Pos.Src = this.src
+	this.g.Tracer.Trace(e)
+}
+
+// an event about the production, see Tracer
+func (this *Prod) event(kind EventKind, from, end int) Event {
+	e := Event{
+		Kind:      kind,
+		Rule:      this.Name,
+		Pos:       Pos{From: from, End: end},
+		Src:       this.src,
+		Directive: this.Directive,
+	}
+	for i, p := range this.g.alts[this.Name].prods {
+		if p == this {
+			e.Alt = i
+		}
+	}
+	return e
+}
+
+// a terminal matched, and p.at is after it
+func (this *pos) traceConsume(what, out string) {
+	this.trace(Event{Kind: Consume, Rule: this.p.Name, Text: what, Pos: Pos{From: this.at - len(out), End: this.at}})
+}
@@ -0,0 +1,77 @@
+package parse_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/test"
+	"github.com/ohait/parse-rec-descent-go"
+)
+
+func tracerGrammar() *parse.Grammar {
+	var g parse.Grammar
+	g.Add("pair", `"(" + num "," num ")"`).Return(func(a, b string) string {
+		return a + b
+	})
+	g.Add("pair", `num`)
+	g.Add("num", `/\d+/`)
+	return &g
+}
+
+func TestTracer(t *testing.T) {
+	g := tracerGrammar()
+	var kinds []string
+	g.Tracer = parse.TracerFunc(func(e parse.Event) {
+		kinds = append(kinds, e.Kind.String())
+	})
+	_, _, err := g.Parse("pair", []byte("(1,2)"))
+	test.NoError(t, err)
+	test.EqualsGo(t, "enter try consume item commit item enter try consume item exit item consume item enter try consume item exit item consume item action exit", strings.Join(kinds, " "))
+
+	var buf bytes.Buffer
+	g.Tracer = &parse.TextTracer{W: &buf}
+	_, _, err = g.Parse("pair", []byte("7"))
+	test.NoError(t, err)
+	t.Logf("%s", buf.String())
+	test.EqualsGo(t, strings.Join([]string{
+		"enter pair @0",
+		" try pair/0 `\"(\" + num \",\" num \")\"` @0",
+		" backtrack pair/0 `\"(\" + num \",\" num \")\"` @0: expected /^\\(/ got \"7\" at 0",
+		" try pair/1 `num` @0",
+		" enter num @0",
+		"  try num/0 `/\\d+/` @0",
+		"  consume num @0-1 /\\d+/ \"7\"",
+		"  item num/0 #0 /\\d+/ @0-1",
+		" exit num @0-1 => 7",
+		" item pair/1 #0 num @0-1",
+		"exit pair @0-1 => 7",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	g.Tracer = &parse.JSONTracer{W: &buf}
+	_, _, err = g.Parse("pair", []byte("(1,x)"))
+	test.Error(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var last map[string]any
+	for _, l := range lines {
+		last = nil
+		test.NoError(t, json.Unmarshal([]byte(l), &last))
+	}
+	test.EqualsGo(t, "exit", last["kind"])
+	test.EqualsGo(t, "pair", last["rule"])
+	test.Contains(t, last["error"].(string), `expected num got "x)"`)
+	test.Contains(t, buf.String(), `{"alt":0,"depth":1,"directive":"\"(\" + num \",\" num \")\"","end":1,"from":1,"kind":"commit","rule":"pair","src":"tracer_test.go:16"}`)
+
+	buf.Reset()
+	g.Tracer = parse.SlogTracer{
+		Logger: slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		Level:  slog.LevelDebug,
+	}
+	_, _, err = g.Parse("pair", []byte("(1,2)"))
+	test.NoError(t, err)
+	test.Contains(t, buf.String(), `level=DEBUG msg="parse action" rule=pair depth=1 from=0 end=5 alt=0 directive="\"(\" + num \",\" num \")\"" result=12`)
+}